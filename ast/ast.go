@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"math/big"
 	"monkey/token"
 	"strings"
 )
@@ -78,6 +79,22 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+type RecordStatement struct {
+	Token  token.Token // token.RECORD
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (rs *RecordStatement) statementNode()       {}
+func (rs *RecordStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *RecordStatement) String() string {
+	fields := []string{}
+	for _, f := range rs.Fields {
+		fields = append(fields, f.String())
+	}
+	return rs.TokenLiteral() + " " + rs.Name.String() + " { " + strings.Join(fields, ", ") + " }"
+}
+
 type ReturnStatement struct {
 	Token       token.Token // token.Return
 	ReturnValue Expression
@@ -117,6 +134,27 @@ func (i *IntegerLiteral) expressionNode()      {}
 func (i *IntegerLiteral) TokenLiteral() string { return i.Token.Literal }
 func (i *IntegerLiteral) String() string       { return i.Token.Literal }
 
+// BigIntegerLiteral is an integer literal outside the range of an int64.
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (b *BigIntegerLiteral) expressionNode()      {}
+func (b *BigIntegerLiteral) TokenLiteral() string { return b.Token.Literal }
+func (b *BigIntegerLiteral) String() string       { return b.Token.Literal }
+
+// DecimalLiteral is a decimal literal such as 12.50d, whose value is Value / 10**Scale.
+type DecimalLiteral struct {
+	Token token.Token
+	Value *big.Int
+	Scale int
+}
+
+func (d *DecimalLiteral) expressionNode()      {}
+func (d *DecimalLiteral) TokenLiteral() string { return d.Token.Literal }
+func (d *DecimalLiteral) String() string       { return d.Token.Literal }
+
 type FloatLiteral struct {
 	Token token.Token
 	Value float64
@@ -212,10 +250,11 @@ func (bs *BlockStatement) String() string {
 }
 
 type FunctionLiteral struct {
-	Name       string
-	Token      token.Token // the fn token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Name        string
+	Token       token.Token // the fn token
+	Parameters  []*Identifier
+	Body        *BlockStatement
+	IsGenerator bool // the body contains a yield expression
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -270,6 +309,15 @@ func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+type BytesLiteral struct {
+	Token token.Token // the contents of the literal, with their escapes
+	Value []byte
+}
+
+func (bl *BytesLiteral) expressionNode()      {}
+func (bl *BytesLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BytesLiteral) String() string       { return `b"` + bl.Token.Literal + `"` }
+
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
@@ -289,10 +337,48 @@ func (al *ArrayLiteral) String() string {
 	return out.String()
 }
 
+type SetLiteral struct {
+	Token    token.Token // the '#{' token
+	Elements []Expression
+}
+
+func (sl *SetLiteral) expressionNode()      {}
+func (sl *SetLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *SetLiteral) String() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range sl.Elements {
+		elements = append(elements, el.String())
+	}
+	out.WriteString("#{")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+type TupleLiteral struct {
+	Token    token.Token // the '(' token
+	Elements []Expression
+}
+
+func (tl *TupleLiteral) expressionNode()      {}
+func (tl *TupleLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TupleLiteral) String() string {
+	elements := []string{}
+	for _, el := range tl.Elements {
+		elements = append(elements, el.String())
+	}
+	if len(elements) == 1 {
+		return "(" + elements[0] + ",)"
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+
 type IndexExpression struct {
-	Token token.Token // the [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // the [ token
+	Left     Expression
+	Index    Expression
+	Optional bool // left?[index] and left?.index yield null when left is null
 }
 
 func (ie *IndexExpression) expressionNode()      {}
@@ -301,15 +387,48 @@ func (ie *IndexExpression) String() string {
 	out := bytes.Buffer{}
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
 	return out.String()
 }
 
+type SliceExpression struct {
+	Token    token.Token // the [ token
+	Left     Expression
+	Start    Expression // nil when omitted
+	End      Expression // nil when omitted
+	Optional bool
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	out := bytes.Buffer{}
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	if se.Optional {
+		out.WriteString("?")
+	}
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // the keys of Pairs in the order they're written
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -318,8 +437,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -328,3 +447,126 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+type ConditionalExpression struct {
+	Token       token.Token // the ? token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (ce *ConditionalExpression) expressionNode()      {}
+func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ConditionalExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ce.Condition.String())
+	out.WriteString(" ? ")
+	out.WriteString(ce.Consequence.String())
+	out.WriteString(" : ")
+	out.WriteString(ce.Alternative.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// OptionalChainExpression marks the end of a chain of indexes, slices and calls containing
+// a?[k] or a?.b: when an optional link finds null, the rest of the chain is skipped and the
+// whole chain yields null.
+type OptionalChainExpression struct {
+	Chain Expression
+}
+
+func (oc *OptionalChainExpression) expressionNode()      {}
+func (oc *OptionalChainExpression) TokenLiteral() string { return oc.Chain.TokenLiteral() }
+func (oc *OptionalChainExpression) String() string       { return oc.Chain.String() }
+
+type CoalesceExpression struct {
+	Token token.Token // the ?? token
+	Left  Expression
+	Right Expression
+}
+
+func (ce *CoalesceExpression) expressionNode()      {}
+func (ce *CoalesceExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CoalesceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ce.Left.String())
+	out.WriteString(" ?? ")
+	out.WriteString(ce.Right.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+type RangeExpression struct {
+	Token     token.Token // the .. or ..= token
+	Start     Expression
+	End       Expression
+	Inclusive bool
+}
+
+func (re *RangeExpression) expressionNode()      {}
+func (re *RangeExpression) TokenLiteral() string { return re.Token.Literal }
+func (re *RangeExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(re.Start.String())
+	out.WriteString(re.Token.Literal)
+	out.WriteString(re.End.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+type ComposeExpression struct {
+	Token  token.Token // the >> token
+	First  Expression
+	Second Expression
+}
+
+func (ce *ComposeExpression) expressionNode()      {}
+func (ce *ComposeExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ComposeExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ce.First.String())
+	out.WriteString(" >> ")
+	out.WriteString(ce.Second.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+type YieldExpression struct {
+	Token token.Token // the yield token
+	Value Expression  // nil for a bare yield
+}
+
+func (ye *YieldExpression) expressionNode()      {}
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YieldExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(yield")
+	if ye.Value != nil {
+		out.WriteString(" ")
+		out.WriteString(ye.Value.String())
+	}
+	out.WriteString(")")
+	return out.String()
+}
+
+// SpawnExpression starts Function in a new task.  spawn f(a, b) passes the call's arguments,
+// while spawn f starts f with none.
+type SpawnExpression struct {
+	Token     token.Token // the spawn token
+	Function  Expression
+	Arguments []Expression
+}
+
+func (se *SpawnExpression) expressionNode()      {}
+func (se *SpawnExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpawnExpression) String() string {
+	args := []string{}
+	for _, a := range se.Arguments {
+		args = append(args, a.String())
+	}
+	return "(spawn " + se.Function.String() + "(" + strings.Join(args, ", ") + "))"
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"monkey/evaluator"
@@ -14,6 +15,7 @@ import (
 
 var cpuProfile = flag.String("cpuprofile", "", "Store cpu profile data")
 var useRepl = flag.Bool("repl", false, "Start the REPL")
+var sandbox = flag.Bool("sandbox", false, "Deny builtins that run commands or read the environment")
 
 func main() {
 	flag.Usage = usage
@@ -22,13 +24,13 @@ func main() {
 	if *cpuProfile != "" {
 		f, err := os.Create(*cpuProfile)
 		if err != nil {
-			fmt.Errorf("unable to store profile data: %+v", err)
+			fmt.Fprintf(os.Stderr, "unable to store profile data: %+v\n", err)
 			os.Exit(1)
 		}
 		defer f.Close()
 		err = pprof.StartCPUProfile(f)
 		if err != nil {
-			fmt.Errorf("unable to store profile data: %+v", err)
+			fmt.Fprintf(os.Stderr, "unable to store profile data: %+v\n", err)
 			os.Exit(1)
 		}
 		defer pprof.StopCPUProfile()
@@ -57,7 +59,8 @@ func runFile(fileName string) {
 	l := lexer.NewFromReader(fileName, f)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	obj := evaluator.Eval(program, object.NewEnvironment())
+	ctx := object.WithPolicy(context.Background(), policy())
+	obj := evaluator.EvalContext(ctx, program, object.NewEnvironment())
 
 	if obj != nil {
 		if obj.Type() == object.ERROR_OBJ {
@@ -71,7 +74,15 @@ func runFile(fileName string) {
 
 func r() {
 	fmt.Printf("Monkey REPL\n")
-	repl.Start(os.Stdin, os.Stdout)
+	repl.StartWithPolicy(os.Stdin, os.Stdout, policy())
+}
+
+// policy returns the policy selected by the flags, nil when everything is allowed.
+func policy() *object.Policy {
+	if *sandbox {
+		return object.SandboxPolicy()
+	}
+	return nil
 }
 
 func usage() {
@@ -98,6 +98,17 @@ const (
 	OpClosure
 	OpGetFree
 	OpCurrentClosure
+	OpJumpNull
+	OpJumpNotNull
+	OpSlice
+	OpRange
+	OpYield
+	OpSpawn
+	OpPow
+	OpSet
+	OpTuple
+	OpCompose
+	OpLessThan
 )
 
 type Definition struct {
@@ -136,6 +147,17 @@ var definitions = map[Opcode]*Definition{
 	OpClosure:        {"OpClosure", []int{2, 1}},
 	OpGetFree:        {"OpClosure", []int{1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+	OpJumpNull:       {"OpJumpNull", []int{2}},
+	OpJumpNotNull:    {"OpJumpNotNull", []int{2}},
+	OpSlice:          {"OpSlice", []int{}},
+	OpRange:          {"OpRange", []int{1}},
+	OpYield:          {"OpYield", []int{}},
+	OpSpawn:          {"OpSpawn", []int{1}},
+	OpPow:            {"OpPow", []int{}},
+	OpSet:            {"OpSet", []int{2}},
+	OpTuple:          {"OpTuple", []int{2}},
+	OpCompose:        {"OpCompose", []int{}},
+	OpLessThan:       {"OpLessThan", []int{}},
 }
 
 func Lookup(op byte) (*Definition, error) {
@@ -2,10 +2,10 @@ package compiler
 
 import (
 	"fmt"
+	"math"
 	"monkey/ast"
 	"monkey/code"
 	"monkey/object"
-	"sort"
 )
 
 type EmittedInstruction struct {
@@ -21,13 +21,23 @@ type Compiler struct {
 	symbolTable         *SymbolTable
 	scopes              []CompilationScope
 	scopeIndex          int
+	policy              *object.Policy
+	registry            *object.Registry
+
+	// optionalJumps holds, for each optional chain being compiled, the jumps its optional
+	// links take when their receiver is null, to be patched to the end of the chain.
+	optionalJumps [][]int
 }
 
 func New() *Compiler {
+	return NewWithRegistry(nil)
+}
+
+// NewWithRegistry returns a compiler for programs calling the builtins in r, or the standard
+// builtins if r is nil.
+func NewWithRegistry(r *object.Registry) *Compiler {
 	symbolTable := NewSymbolTable()
-	for i, k := range object.Builtins {
-		symbolTable.DefineBuiltin(i, k.Name)
-	}
+	DefineBuiltins(symbolTable, r)
 
 	mainScope := CompilationScope{
 		instructions:        code.Instructions{},
@@ -43,6 +53,7 @@ func New() *Compiler {
 		symbolTable:         symbolTable,
 		scopes:              []CompilationScope{mainScope},
 		scopeIndex:          0,
+		registry:            r,
 	}
 }
 
@@ -53,6 +64,17 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 	return compiler
 }
 
+// SetRegistry sets the builtins checked against the policy.  The builtins a program can call
+// are the ones defined in the symbol table; see DefineBuiltins.
+func (c *Compiler) SetRegistry(r *object.Registry) {
+	c.registry = r
+}
+
+// SetPolicy makes references to builtins that p doesn't allow compile errors.
+func (c *Compiler) SetPolicy(p *object.Policy) {
+	c.policy = p
+}
+
 func (c *Compiler) Compile(node ast.Node) error {
 	var err error
 	switch node := node.(type) {
@@ -93,6 +115,15 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.IntegerLiteral:
 		integer := &object.Integer{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.BigIntegerLiteral:
+		integer := &object.BigInt{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.DecimalLiteral:
+		decimal := &object.Decimal{Value: node.Value, Scale: node.Scale}
+		c.emit(code.OpConstant, c.addConstant(decimal))
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
 	case *ast.IfExpression:
 		err = c.Compile(node.Condition)
 		if err != nil {
@@ -136,10 +167,19 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.Identifier:
 		sym, ok := c.symbolTable.Resolve(node.Value)
 		if !ok {
-			err = fmt.Errorf("undefined variable %s", node.Value)
-		} else {
-			c.loadSymbol(sym)
+			return fmt.Errorf("undefined variable %s", node.Value)
+		}
+		if sym.Scope == BuiltinScope {
+			if sym.Index > math.MaxUint8 {
+				return fmt.Errorf("too many builtins to call %s", sym.Name)
+			}
+			builtin, _ := c.registry.Lookup(sym.Name)
+			err = c.policy.CheckBuiltin(sym.Name, builtin)
+			if err != nil {
+				return err
+			}
 		}
+		c.loadSymbol(sym)
 	case *ast.LetStatement:
 		sym := c.symbolTable.Define(node.Name.Value)
 		err = c.Compile(node.Value)
@@ -147,6 +187,19 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
+		if sym.Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, sym.Index)
+		} else {
+			c.emit(code.OpSetLocal, sym.Index)
+		}
+	case *ast.RecordStatement:
+		sym := c.symbolTable.Define(node.Name.Value)
+		recordType := &object.RecordType{Name: node.Name.Value}
+		for _, f := range node.Fields {
+			recordType.Fields = append(recordType.Fields, f.Value)
+		}
+		c.emit(code.OpConstant, c.addConstant(recordType))
+
 		if sym.Scope == GlobalScope {
 			c.emit(code.OpSetGlobal, sym.Index)
 		} else {
@@ -155,6 +208,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 	case *ast.StringLiteral:
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
+	case *ast.BytesLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Bytes{Value: node.Value}))
 	case *ast.ArrayLiteral:
 		for _, e := range node.Elements {
 			err = c.Compile(e)
@@ -164,14 +219,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 		c.emit(code.OpArray, len(node.Elements))
 	case *ast.HashLiteral:
-		keys := []ast.Expression{}
-		for k := range node.Pairs {
-			keys = append(keys, k)
-		}
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
-		for _, k := range keys {
+		for _, k := range node.Keys {
 			err = c.Compile(k)
 			if err != nil {
 				return err
@@ -182,6 +230,22 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.TupleLiteral:
+		for _, el := range node.Elements {
+			err = c.Compile(el)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpTuple, len(node.Elements))
+	case *ast.SetLiteral:
+		for _, el := range node.Elements {
+			err = c.Compile(el)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSet, len(node.Elements))
 	case *ast.FunctionLiteral:
 		c.enterScope()
 
@@ -213,9 +277,11 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 		compiledFn := &object.CompiledFunction{
+			Name:          node.Name,
 			Instructions:  instructions,
 			NumLocals:     numLocals,
-			NumParameters: len(node.Parameters)}
+			NumParameters: len(node.Parameters),
+			IsGenerator:   node.IsGenerator}
 
 		fnIndex := c.addConstant(compiledFn)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
@@ -229,11 +295,130 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if err != nil {
 			return err
 		}
+
+		if node.Optional {
+			if err := c.emitOptionalJump(); err != nil {
+				return err
+			}
+		}
+
 		err = c.Compile(node.Index)
 		if err != nil {
 			return err
 		}
 		c.emit(code.OpIndex)
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		if node.Optional {
+			if err := c.emitOptionalJump(); err != nil {
+				return err
+			}
+		}
+
+		for _, bound := range []ast.Expression{node.Start, node.End} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+			err = c.Compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSlice)
+	case *ast.ComposeExpression:
+		err = c.Compile(node.First)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(node.Second)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpCompose)
+	case *ast.RangeExpression:
+		err = c.Compile(node.Start)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(node.End)
+		if err != nil {
+			return err
+		}
+		inclusive := 0
+		if node.Inclusive {
+			inclusive = 1
+		}
+		c.emit(code.OpRange, inclusive)
+	case *ast.YieldExpression:
+		if node.Value == nil {
+			c.emit(code.OpNull)
+		} else {
+			err = c.Compile(node.Value)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpYield)
+	case *ast.SpawnExpression:
+		err = c.Compile(node.Function)
+		if err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			err = c.Compile(a)
+			if err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSpawn, len(node.Arguments))
+	case *ast.ConditionalExpression:
+		err = c.Compile(node.Condition)
+		if err != nil {
+			return err
+		}
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		err = c.Compile(node.Consequence)
+		if err != nil {
+			return err
+		}
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+		err = c.Compile(node.Alternative)
+		if err != nil {
+			return err
+		}
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+	case *ast.OptionalChainExpression:
+		c.optionalJumps = append(c.optionalJumps, nil)
+		err = c.Compile(node.Chain)
+		jumps := c.optionalJumps[len(c.optionalJumps)-1]
+		c.optionalJumps = c.optionalJumps[:len(c.optionalJumps)-1]
+		if err != nil {
+			return err
+		}
+		for _, pos := range jumps {
+			c.changeOperand(pos, len(c.currentInstructions()))
+		}
+	case *ast.CoalesceExpression:
+		err = c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+		jumpNotNullPos := c.emit(code.OpJumpNotNull, 9999)
+		c.emit(code.OpPop)
+
+		err = c.Compile(node.Right)
+		if err != nil {
+			return err
+		}
+		c.changeOperand(jumpNotNullPos, len(c.currentInstructions()))
 	case *ast.CallExpression:
 		err := c.Compile(node.Function)
 		if err != nil {
@@ -282,6 +467,19 @@ func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
 	}
 }
 
+// emitOptionalJump emits the jump an optional link takes past the rest of its chain when its
+// receiver is null, leaving the null as the value of the chain.  The parser wraps every
+// optional link in an ast.OptionalChainExpression, and a link outside of one is an error.
+func (c *Compiler) emitOptionalJump() error {
+	last := len(c.optionalJumps) - 1
+	if last < 0 {
+		return fmt.Errorf("optional link outside of an optional chain")
+	}
+	pos := c.emit(code.OpJumpNull, 9999)
+	c.optionalJumps[last] = append(c.optionalJumps[last], pos)
+	return nil
+}
+
 func (c *Compiler) changeOperand(opPos int, operand int) {
 	op := code.Opcode(c.currentInstructions()[opPos])
 	newInstruction := code.Make(op, operand)
@@ -289,20 +487,6 @@ func (c *Compiler) changeOperand(opPos int, operand int) {
 }
 
 func (c *Compiler) emitInfixExpression(node *ast.InfixExpression) error {
-	if node.Operator == "<" {
-		err := c.Compile(node.Right)
-		if err != nil {
-			return err
-		}
-
-		err = c.Compile(node.Left)
-		if err != nil {
-			return err
-		}
-		c.emit(code.OpGreaterThan)
-		return nil
-	}
-
 	err := c.Compile(node.Left)
 	if err != nil {
 		return err
@@ -321,8 +505,12 @@ func (c *Compiler) emitInfixExpression(node *ast.InfixExpression) error {
 		c.emit(code.OpMul)
 	case "/":
 		c.emit(code.OpDiv)
+	case "**":
+		c.emit(code.OpPow)
 	case ">":
 		c.emit(code.OpGreaterThan)
+	case "<":
+		c.emit(code.OpLessThan)
 	case "==":
 		c.emit(code.OpEqual)
 	case "!=":
@@ -363,6 +551,7 @@ func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
 		Instructions: c.currentInstructions(),
 		Constants:    c.constants,
+		Builtins:     c.symbolTable.Builtins(),
 	}
 }
 
@@ -407,6 +596,7 @@ func (c *Compiler) loadSymbol(s Symbol) {
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	Builtins     []string // the names of the builtins OpGetBuiltin operands index
 }
 
 type CompilationScope struct {
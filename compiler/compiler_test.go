@@ -66,11 +66,11 @@ func TestIntegerArithmetic(t *testing.T) {
 		},
 		{
 			"1 < 2",
-			[]interface{}{2, 1},
+			[]interface{}{1, 2},
 			[]code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpGreaterThan),
+				code.Make(code.OpLessThan),
 				code.Make(code.OpPop),
 			},
 		},
@@ -409,6 +409,54 @@ func TestHashliterals(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestSetLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"#{}", []interface{}{},
+			[]code.Instructions{
+				code.Make(code.OpSet, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"#{1, 2 + 3}", []interface{}{1, 2, 3},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpSet, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestTupleLiterals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			"()", []interface{}{},
+			[]code.Instructions{
+				code.Make(code.OpTuple, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			"(1, 2 + 3)", []interface{}{1, 2, 3},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpAdd),
+				code.Make(code.OpTuple, 2),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
 func TestIndexExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -860,3 +908,186 @@ func TestRecursiveFunctions(t *testing.T) {
 	}
 	runCompilerTests(t, tests)
 }
+
+func TestPipeExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			`[1] |> push(2) |> len`,
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpGetBuiltin, 5),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestConditionalAndOptionalOperators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			`true ? 10 : 20`,
+			[]interface{}{10, 20},
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 13),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			`1 ?? 2`,
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJumpNotNull, 10),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			`[1]?[0]`,
+			[]interface{}{1, 0},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpJumpNull, 13),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			`[1]?[0][1]`,
+			[]interface{}{1, 0, 1},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpJumpNull, 17),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			`[1, 2][1:]`,
+			[]interface{}{1, 2, 1},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestRangeExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			`0..10; 1..=2`,
+			[]interface{}{0, 10, 1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpRange, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpRange, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestSpawnExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			`let f = fn(a) { a }; spawn f(1)`,
+			[]interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			[]code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSpawn, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestPolicyDeniesBuiltins(t *testing.T) {
+	c := New()
+	c.SetPolicy(object.SandboxPolicy())
+	err := c.Compile(parse(`let f = fn() { exec("ls") }`))
+	expected := "exec denied by policy: requires the process capability"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong compiler error: want=%q, got=%v", expected, err)
+	}
+
+	c = New()
+	c.SetPolicy(object.SandboxPolicy())
+	err = c.Compile(parse(`let exec = fn(cmd) { cmd }; exec("ls"); len("ls")`))
+	if err != nil {
+		t.Errorf("compiler error for allowed builtins: %s", err)
+	}
+}
+
+func TestComposeExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			`rest >> len`,
+			[]interface{}{},
+			[]code.Instructions{
+				code.Make(code.OpGetBuiltin, 4),
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpCompose),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+	runCompilerTests(t, tests)
+}
+
+func TestOptionalLinkOutsideChain(t *testing.T) {
+	// the parser always closes a chain; a hand built tree that doesn't is an error, not a panic
+	program := &ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{
+		Expression: &ast.IndexExpression{Left: &ast.Identifier{Value: "len"}, Index: &ast.IntegerLiteral{Value: 0}, Optional: true},
+	}}}
+	err := New().Compile(program)
+	expected := "optional link outside of an optional chain"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong compiler error: want=%q, got=%v", expected, err)
+	}
+}
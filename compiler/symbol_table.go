@@ -1,5 +1,10 @@
 package compiler
 
+import (
+	"monkey/object"
+	"slices"
+)
+
 type SymbolScope string
 
 const (
@@ -21,6 +26,7 @@ type SymbolTable struct {
 	store          map[string]Symbol
 	numDefinitions int
 	FreeSymbols    []Symbol
+	builtins       []string // builtin names by index, in the outermost table
 }
 
 func NewSymbolTable() *SymbolTable {
@@ -64,9 +70,28 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	symbol := Symbol{name, BuiltinScope, index}
 	s.store[name] = symbol
+	if index >= len(s.builtins) {
+		s.builtins = append(s.builtins, make([]string, index+1-len(s.builtins))...)
+	}
+	s.builtins[index] = name
 	return symbol
 }
 
+// Builtins returns the names of the builtins defined in the outermost table, by index.
+func (s *SymbolTable) Builtins() []string {
+	for s.outer != nil {
+		s = s.outer
+	}
+	return slices.Clone(s.builtins)
+}
+
+// DefineBuiltins defines the builtins in r, or the standard builtins if r is nil.
+func DefineBuiltins(s *SymbolTable, r *object.Registry) {
+	for i, name := range r.Names() {
+		s.DefineBuiltin(i, name)
+	}
+}
+
 func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	s.FreeSymbols = append(s.FreeSymbols, original)
 	symbol := Symbol{
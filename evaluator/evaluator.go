@@ -1,17 +1,62 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
 	"monkey/ast"
 	"monkey/object"
+	"slices"
 )
 
 var (
 	NULL  = &object.Null{}
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
+
+	// skipped is the value of the links of an optional chain after one found null; the
+	// chain's ast.OptionalChainExpression turns it back into NULL.
+	skipped object.Object = &skippedChain{}
 )
 
+// skippedChain isn't zero-sized, so skipped can't share an address with NULL.
+type skippedChain struct {
+	object.Null
+	_ byte
+}
+
+// EvalContext evaluates node until it completes or ctx is done.  If ctx is canceled or its
+// deadline passes, it returns an *object.Error whose cause is object.ErrCanceled or
+// object.ErrDeadline.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	prev := env.SetContext(object.WithCaller(ctx, caller{}))
+	defer env.SetContext(prev)
+	return Eval(node, env)
+}
+
+// CallFunction calls fn with args under ctx, the way EvalContext evaluates a program, and
+// returns its result.
+func CallFunction(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	ctx = object.WithCaller(ctx, caller{})
+	if object.TaskFromContext(ctx) == nil {
+		task := object.NewScheduler().NewTask("main")
+		ctx = object.WithTask(ctx, task)
+		task.Start()
+		defer task.Finish(nil)
+	}
+	return caller{}.CallFunction(ctx, fn, args...)
+}
+
+// caller calls functions for builtins.
+type caller struct{}
+
+func (caller) CallFunction(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	result := applyFunction(ctx, fn, args)
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
 	switch n := node.(type) {
 	case *ast.InfixExpression:
@@ -23,7 +68,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(n.Operator, left, right)
+		return allocated(env, evalInfixExpression(n.Operator, left, right, env))
 	case *ast.PrefixExpression:
 		right := Eval(n.Right, env)
 		if isError(right) {
@@ -36,6 +81,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(n.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: n.Value}
+	case *ast.BigIntegerLiteral:
+		return &object.BigInt{Value: n.Value}
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: n.Value, Scale: n.Scale}
 	case *ast.FloatLiteral:
 		return &object.Float{Value: n.Value}
 	case *ast.Boolean:
@@ -56,69 +105,134 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 			return val
 		}
 		env.Set(n.Name.Value, val)
+	case *ast.RecordStatement:
+		recordType := &object.RecordType{Name: n.Name.Value}
+		for _, f := range n.Fields {
+			recordType.Fields = append(recordType.Fields, f.Value)
+		}
+		env.Set(n.Name.Value, recordType)
 	case *ast.Identifier:
 		val := evalIdentifier(n, env)
 		return val
 	case *ast.FunctionLiteral:
 		params := n.Parameters
 		body := n.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: n.Name, Parameters: params, Env: env, Body: body, IsGenerator: n.IsGenerator}
 	case *ast.CallExpression:
 		function := Eval(n.Function, env)
-		if isError(function) {
+		if isError(function) || function == skipped {
 			return function
 		}
 		args := evalExpressions(n.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return applyFunction(env.Context(), function, args)
 	case *ast.StringLiteral:
 		return &object.String{Value: n.Value}
+	case *ast.BytesLiteral:
+		return &object.Bytes{Value: n.Value}
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(n.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		return allocated(env, &object.Array{Elements: elements})
 	case *ast.IndexExpression:
 		left := Eval(n.Left, env)
-		if isError(left) {
+		if isError(left) || left == skipped {
 			return left
 		}
+		if n.Optional && isNull(left) {
+			return skipped
+		}
 		index := Eval(n.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return allocated(env, evalSliceExpression(n, env))
+	case *ast.ComposeExpression:
+		first := Eval(n.First, env)
+		if isError(first) {
+			return first
+		}
+		second := Eval(n.Second, env)
+		if isError(second) {
+			return second
+		}
+		composed, err := object.Compose(first, second)
+		if err != nil {
+			return err
+		}
+		return composed
+	case *ast.RangeExpression:
+		return evalRangeExpression(n, env)
+	case *ast.YieldExpression:
+		return evalYieldExpression(n, env)
+	case *ast.SpawnExpression:
+		return evalSpawnExpression(n, env)
 	case *ast.HashLiteral:
-		return evalHashLiteral(n, env)
+		return allocated(env, evalHashLiteral(n, env))
+	case *ast.TupleLiteral:
+		elements := evalExpressions(n.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return allocated(env, &object.Tuple{Elements: elements})
+	case *ast.SetLiteral:
+		return allocated(env, evalSetLiteral(n, env))
+	case *ast.ConditionalExpression:
+		condition := Eval(n.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return Eval(n.Consequence, env)
+		}
+		return Eval(n.Alternative, env)
+	case *ast.OptionalChainExpression:
+		result := Eval(n.Chain, env)
+		if result == skipped {
+			return NULL
+		}
+		return result
+	case *ast.CoalesceExpression:
+		left := Eval(n.Left, env)
+		if isError(left) || !isNull(left) {
+			return left
+		}
+		return Eval(n.Right, env)
 	}
 	return nil
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+func evalInfixExpression(operator string, left, right object.Object, env *object.Environment) object.Object {
 	switch {
-	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(operator, left, right)
-	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
-	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
-		return evalFloatInfixExpression(operator, left, right)
 	case operator == "==":
-		leftBool := left.(*object.Boolean).Value
-		rightBool := right.(*object.Boolean).Value
-		if leftBool == rightBool {
-			return TRUE
-		}
-		return FALSE
+		return nativeBoolToBoolObject(object.Equals(left, right))
 	case operator == "!=":
-		leftBool := left.(*object.Boolean).Value
-		rightBool := right.(*object.Boolean).Value
-		if leftBool != rightBool {
-			return TRUE
+		return nativeBoolToBoolObject(!object.Equals(left, right))
+	case operator == "<" || operator == ">":
+		c, err := object.Compare(left, right)
+		if err != nil {
+			return newError("%s", err)
 		}
-		return FALSE
+		if operator == "<" {
+			c = -c
+		}
+		return nativeBoolToBoolObject(c > 0)
+	case object.IsNumber(left) && object.IsNumber(right):
+		result, err := object.Arithmetic(env.Context(), operator, left, right)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return result
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.BYTES_OBJ && right.Type() == object.BYTES_OBJ:
+		return evalBytesInfixExpression(operator, left, right)
 	default:
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -135,69 +249,12 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	}
 }
 
-func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
+func evalBytesInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Bytes).Value
+	rightVal := right.(*object.Bytes).Value
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
-	case "<":
-		if leftVal < rightVal {
-			return TRUE
-		}
-		return FALSE
-	case ">":
-		if leftVal > rightVal {
-			return TRUE
-		}
-		return FALSE
-	case "==":
-		if leftVal == rightVal {
-			return TRUE
-		}
-		return FALSE
-	case "!=":
-		if leftVal != rightVal {
-			return TRUE
-		}
-		return FALSE
-	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-	}
-}
-
-func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
-	leftVal := left.(*object.Float).Value
-	rightVal := right.(*object.Float).Value
-	switch operator {
-	case "+":
-		return &object.Float{Value: leftVal + rightVal}
-	case "-":
-		return &object.Float{Value: leftVal - rightVal}
-	case "*":
-		return &object.Float{Value: leftVal * rightVal}
-	case "/":
-		return &object.Float{Value: leftVal / rightVal}
-	case "<":
-		if leftVal < rightVal {
-			return TRUE
-		}
-		return FALSE
-	case ">":
-		if leftVal > rightVal {
-			return TRUE
-		}
-		return FALSE
-	case "==":
-		return newError("use cmp() to compare floating point values")
-	case "!=":
-		return newError("use cmp() to compare floating point values")
+		return &object.Bytes{Value: slices.Concat(leftVal, rightVal)}
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -228,16 +285,10 @@ func evalBangOperatorExpression(obj object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	switch right.Type() {
-	case object.INTEGER_OBJ:
-		value := right.(*object.Integer).Value
-		return &object.Integer{Value: -value}
-	case object.FLOAT_OBJ:
-		value := right.(*object.Float).Value
-		return &object.Float{Value: -value}
-	default:
+	if !object.IsNumber(right) {
 		return newError("unknown operator: -%s", right.Type())
 	}
+	return object.Negate(right)
 }
 
 func nativeBoolToBoolObject(value bool) *object.Boolean {
@@ -248,13 +299,30 @@ func nativeBoolToBoolObject(value bool) *object.Boolean {
 }
 
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	ctx := env.Context()
+	if object.TaskFromContext(ctx) == nil {
+		task := object.NewScheduler().NewTask("main")
+		prev := env.SetContext(object.WithCaller(object.WithTask(ctx, task), caller{}))
+		defer env.SetContext(prev)
+		task.Start()
+		defer task.Finish(nil)
+	}
+
+	budget := object.BudgetFromContext(env.Context())
 	var result object.Object
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		if err := budget.Step(); err != nil {
+			result = err
+		} else {
+			result = Eval(statement, env)
+		}
 		switch r := result.(type) {
 		case *object.ReturnValue:
 			return r.Value
 		case *object.Error:
+			if r.Cause != nil {
+				r.AddFrame("<main>")
+			}
 			return r
 		}
 	}
@@ -264,7 +332,11 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
+	budget := object.BudgetFromContext(env.Context())
 	for _, statement := range block.Statements {
+		if err := budget.Step(); err != nil {
+			return err
+		}
 		result = Eval(statement, env)
 
 		if result != nil {
@@ -292,6 +364,9 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Obje
 }
 
 func isTruthy(obj object.Object) bool {
+	if isNull(obj) {
+		return false
+	}
 	switch obj {
 	case NULL, FALSE:
 		return false
@@ -301,6 +376,23 @@ func isTruthy(obj object.Object) bool {
 	return true
 }
 
+// isNull reports whether obj is null, including the nil result of a void builtin.
+func isNull(obj object.Object) bool {
+	return obj == nil || obj.Type() == object.NULL_OBJ
+}
+
+// allocated charges obj, if it's a newly created string, bytes, array or hash, to the budget of
+// the program.
+func allocated(env *object.Environment, obj object.Object) object.Object {
+	switch obj.(type) {
+	case *object.String, *object.Bytes, *object.Array, *object.Hash, *object.Set, *object.Tuple, *object.Record:
+		if err := object.BudgetFromContext(env.Context()).Allocate(obj); err != nil {
+			return err
+		}
+	}
+	return obj
+}
+
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
@@ -315,7 +407,21 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	ctx := env.Context()
+	policy := object.PolicyFromContext(ctx)
+	if node.Value == "ENV" {
+		if err := policy.CheckCapability("ENV", object.CapEnv); err != nil {
+			return &object.Error{Message: fmt.Sprintf("%s: %s", node.Token.LineInfo, err), Cause: err}
+		}
+		return object.EnvironmentHash()
+	}
+	if builtin, ok := object.RegistryFromContext(ctx).Lookup(node.Value); ok {
+		if err := policy.CheckBuiltin(node.Value, builtin); err != nil {
+			return &object.Error{Message: fmt.Sprintf("%s: %s", node.Token.LineInfo, err), Cause: err}
+		}
+		if builtin.Value != nil {
+			return builtin.Value
+		}
 		return builtin
 	}
 	return newError("%s: identifier not found: %s", node.Token.LineInfo, node.Value)
@@ -333,15 +439,50 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func applyFunction(ctx context.Context, fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		if ctx.Err() != nil {
+			return traceStack(object.NewInterruptError(ctx), fn)
+		}
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+		if fn.IsGenerator {
+			return newGenerator(ctx, fn, args)
+		}
+		if task := object.TaskFromContext(ctx); task != nil {
+			maxDepth := object.BudgetFromContext(ctx).Limits().MaxCallDepth
+			if depth := task.EnterCall(); maxDepth > 0 && depth > maxDepth {
+				task.ExitCall()
+				return traceStack(object.NewLimitError("MaxCallDepth", int64(maxDepth)), fn)
+			}
+			defer task.ExitCall()
+		}
+		extendedEnv := extendFunctionEnv(ctx, fn, args)
+		evaluated := unwrapReturnValue(Eval(fn.Body, extendedEnv))
+		if errObj, ok := evaluated.(*object.Error); ok && errObj.Cause != nil {
+			return traceStack(errObj, fn)
+		}
+		return evaluated
+	case *object.Composition:
+		result := applyFunction(ctx, fn.First, args)
+		if isError(result) {
+			return result
+		}
+		if result == nil {
+			result = NULL
+		}
+		return applyFunction(ctx, fn.Second, []object.Object{result})
+	case *object.RecordType:
+		record, err := fn.New(args)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return record
 	case *object.Builtin:
-		result := fn.Fn(args...)
-		if fn.Void {
+		result := fn.Fn(ctx, args...)
+		if fn.Void && !isError(result) {
 			return nil
 		}
 		return result
@@ -350,8 +491,19 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// traceStack adds fn to the stack trace of an error that stops the program as it unwinds.
+func traceStack(err *object.Error, fn *object.Function) *object.Error {
+	name := fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	err.AddFrame(name)
+	return err
+}
+
+func extendFunctionEnv(ctx context.Context, fn *object.Function, args []object.Object) *object.Environment {
 	env := object.NewEnclosedEnvironment(fn.Env)
+	env.SetContext(ctx)
 	for paramIdx, param := range fn.Parameters {
 		env.Set(param.Value, args[paramIdx])
 	}
@@ -369,59 +521,168 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
+	case left.Type() == object.RANGE_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalRangeIndexExpression(left, index)
+	case left.Type() == object.TUPLE_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalTupleIndexExpression(left, index)
+	case left.Type() == object.BYTES_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalBytesIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.RECORD_OBJ && index.Type() == object.STRING_OBJ:
+		value, err := left.(*object.Record).Field(index.(*object.String).Value)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return value
+	case index.Type() == object.STRING_OBJ:
+		return evalMethodExpression(left, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
+func evalMethodExpression(left, index object.Object) object.Object {
+	provider, ok := left.(object.MethodProvider)
+	if !ok {
+		return newError("index operator not supported: %s", left.Type())
+	}
+	name := index.(*object.String).Value
+	method, ok := provider.Method(name)
+	if !ok {
+		return newError("undefined method %s for %s", name, left.Type())
+	}
+	return method
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	h := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
-	if !ok {
+	if _, ok := object.HashKeyOf(index); !ok {
 		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := h.Pairs[key.HashKey()]
+	value, ok := h.Get(index)
 	if !ok {
 		return NULL
 	}
-	return pair.Value
+	return value
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	end := int64(len(arrayObject.Elements) - 1)
-
-	if idx < 0 || idx > end {
+	idx, ok := object.ResolveIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if !ok {
 		return NULL
 	}
 	return arrayObject.Elements[idx]
 }
 
+func evalTupleIndexExpression(tuple, index object.Object) object.Object {
+	tupleObject := tuple.(*object.Tuple)
+	idx, ok := object.ResolveIndex(index.(*object.Integer).Value, len(tupleObject.Elements))
+	if !ok {
+		return NULL
+	}
+	return tupleObject.Elements[idx]
+}
+
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	result, ok := object.StringIndex(str.(*object.String), index.(*object.Integer).Value)
+	if !ok {
+		return NULL
+	}
+	return result
+}
+
+func evalBytesIndexExpression(b, index object.Object) object.Object {
+	result, ok := object.BytesIndex(b.(*object.Bytes), index.(*object.Integer).Value)
+	if !ok {
+		return NULL
+	}
+	return result
+}
+
+func evalSliceExpression(se *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(se.Left, env)
+	if isError(left) || left == skipped {
+		return left
+	}
+	if se.Optional && isNull(left) {
+		return skipped
+	}
+
+	bounds := []object.Object{NULL, NULL}
+	for i, bound := range []ast.Expression{se.Start, se.End} {
+		if bound == nil {
+			continue
+		}
+		bounds[i] = Eval(bound, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+	return object.Slice(left, bounds[0], bounds[1])
+}
+
+func evalRangeExpression(re *ast.RangeExpression, env *object.Environment) object.Object {
+	start := Eval(re.Start, env)
+	if isError(start) {
+		return start
+	}
+	end := Eval(re.End, env)
+	if isError(end) {
+		return end
+	}
+
+	if start.Type() != object.INTEGER_OBJ || end.Type() != object.INTEGER_OBJ {
+		return newError("range bounds must be INTEGER, got %s..%s", start.Type(), end.Type())
+	}
+	return object.NewRange(start.(*object.Integer).Value, end.(*object.Integer).Value, re.Inclusive)
+}
+
+func evalRangeIndexExpression(r, index object.Object) object.Object {
+	result, ok := object.RangeIndex(r.(*object.Range), index.(*object.Integer).Value)
+	if !ok {
+		return NULL
+	}
+	return result
+}
+
+func evalSetLiteral(node *ast.SetLiteral, env *object.Environment) object.Object {
+	elements := evalExpressions(node.Elements, env)
+	if len(elements) == 1 && isError(elements[0]) {
+		return elements[0]
+	}
+	set := &object.Set{}
+	for _, el := range elements {
+		if err := set.Add(el); err != nil {
+			return newError("%s", err)
+		}
+	}
+	return set
+}
+
 func evalHashLiteral(hash *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	result := &object.Hash{}
 
-	for keyNode, valueNode := range hash.Pairs {
+	for _, keyNode := range hash.Keys {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
+		if _, ok := object.HashKeyOf(key); !ok {
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(hash.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		result.Set(key, value)
 	}
-	return &object.Hash{Pairs: pairs}
+	return result
 }
@@ -0,0 +1,78 @@
+package evaluator
+
+import (
+	"context"
+	"iter"
+	"monkey/ast"
+	"monkey/object"
+	"runtime"
+)
+
+// coroutine runs a generator body as a pull iterator, so the body and the caller of Resume
+// switch between them without channels.  The iterator starts on the first Resume.
+type coroutine struct {
+	ctx    context.Context
+	fn     *object.Function
+	args   []object.Object
+	next   func() (object.Object, bool)
+	stop   func()
+	emit   func(object.Object) bool // the iterator's yield
+	sent   object.Object            // the value sent in by the latest Resume
+	result object.Object            // what the body returned
+}
+
+func newGenerator(ctx context.Context, fn *object.Function, args []object.Object) *object.Generator {
+	co := &coroutine{ctx: ctx, fn: fn, args: args}
+	gen := object.NewGenerator(co)
+
+	// An abandoned generator leaves its body parked in yield; stopping it unwinds the body.
+	runtime.SetFinalizer(gen, func(*object.Generator) {
+		if co.stop != nil {
+			go co.stop()
+		}
+	})
+	return gen
+}
+
+func (co *coroutine) Resume(sent object.Object) (object.Object, bool) {
+	if co.next == nil {
+		co.next, co.stop = iter.Pull(co.run)
+	}
+	co.sent = sent
+	value, ok := co.next()
+	if !ok {
+		return co.result, true
+	}
+	return value, false
+}
+
+func (co *coroutine) run(emit func(object.Object) bool) {
+	co.emit = emit
+	env := extendFunctionEnv(co.ctx, co.fn, co.args)
+	env.SetCoroutine(co)
+	co.result = unwrapReturnValue(Eval(co.fn.Body, env))
+}
+
+// yield hands value to the caller of Resume and returns the next value sent in.
+func (co *coroutine) yield(value object.Object) object.Object {
+	if !co.emit(value) {
+		return newError("generator abandoned")
+	}
+	return co.sent
+}
+
+func evalYieldExpression(ye *ast.YieldExpression, env *object.Environment) object.Object {
+	co, ok := env.Coroutine().(*coroutine)
+	if !ok {
+		return newError("%s: yield outside of a generator", ye.Token.LineInfo)
+	}
+
+	var value object.Object = NULL
+	if ye.Value != nil {
+		value = Eval(ye.Value, env)
+		if isError(value) {
+			return value
+		}
+	}
+	return co.yield(value)
+}
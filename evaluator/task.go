@@ -0,0 +1,32 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// evalSpawnExpression calls the function in a new task on its own goroutine and returns the
+// task.  The task's environments share the enclosing environments of the function.
+func evalSpawnExpression(se *ast.SpawnExpression, env *object.Environment) object.Object {
+	function := Eval(se.Function, env)
+	if isError(function) {
+		return function
+	}
+	switch function.(type) {
+	case *object.Function, *object.Builtin, *object.Composition:
+	default:
+		return newError("spawn of non-function %s", function.Type())
+	}
+	args := evalExpressions(se.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	task := object.SchedulerFromContext(env.Context()).NewTask("")
+	ctx := object.WithTask(env.Context(), task)
+	task.Start()
+	go func() {
+		task.Finish(applyFunction(ctx, function, args))
+	}()
+	return task
+}
@@ -51,8 +51,15 @@ func (l *Lexer) NextToken() token.Token {
 	lineInfo := l.sourceHandle.LineInfo(l.lineNo, l.charNo)
 	switch l.ch {
 	case '#':
-		l.skipComment()
-		tok = l.NextToken()
+		// #{ always starts a set, so a comment whose text begins with { needs a space after
+		// the #, as in # {...}, or it lexes as code
+		if l.peekChar() == '{' {
+			l.readChar()
+			tok = token.Token{Type: token.SET_BRACE, Literal: "#{", LineInfo: lineInfo}
+		} else {
+			l.skipComment()
+			return l.NextToken()
+		}
 	case '=':
 		peek := l.peekChar()
 		if peek == '=' {
@@ -90,13 +97,36 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch, lineInfo)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch, lineInfo)
+		if l.peekChar() == '*' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.POWER, Literal: literal, LineInfo: lineInfo}
+		} else {
+			tok = newToken(token.ASTERISK, l.ch, lineInfo)
+		}
 	case '/':
 		tok = newToken(token.SLASH, l.ch, lineInfo)
 	case '<':
 		tok = newToken(token.LT, l.ch, lineInfo)
 	case '>':
-		tok = newToken(token.GT, l.ch, lineInfo)
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.COMPOSE, Literal: literal, LineInfo: lineInfo}
+		} else {
+			tok = newToken(token.GT, l.ch, lineInfo)
+		}
+	case '|':
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.PIPE, Literal: literal, LineInfo: lineInfo}
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch, lineInfo)
+		}
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
@@ -107,13 +137,46 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.RBRACKET, l.ch, lineInfo)
 	case ':':
 		tok = newToken(token.COLON, l.ch, lineInfo)
+	case '.':
+		if l.peekChar() == '.' {
+			l.readChar()
+			tok = token.Token{Type: token.RANGE, Literal: "..", LineInfo: lineInfo}
+			if l.peekChar() == '=' {
+				l.readChar()
+				tok = token.Token{Type: token.RANGE_INCLUSIVE, Literal: "..=", LineInfo: lineInfo}
+			}
+		} else {
+			tok = newToken(token.DOT, l.ch, lineInfo)
+		}
+	case '?':
+		var tokType token.TokenType
+		switch l.peekChar() {
+		case '?':
+			tokType = token.COALESCE
+		case '.':
+			tokType = token.OPTIONAL_DOT
+		}
+		if tokType != "" {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: tokType, Literal: literal, LineInfo: lineInfo}
+		} else {
+			tok = newToken(token.QUESTION, l.ch, lineInfo)
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
 		tok.LineInfo = lineInfo
 	default:
 		readNextChar = false
-		if isLetter(l.ch) {
+		if l.ch == 'b' && l.peekChar() == '"' {
+			l.readChar()
+			tok.Type = token.BYTES
+			tok.Literal = l.readBytes()
+			tok.LineInfo = lineInfo
+			readNextChar = true
+		} else if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			tok.LineInfo = lineInfo
@@ -170,12 +233,19 @@ func (l *Lexer) readNumber() (token.TokenType, string) {
 	for classifier(l.ch) {
 		buffer = append(buffer, l.ch)
 		l.readChar()
-		if l.ch == '.' {
+		// a second '.' starts a range operator, as in 0..10
+		if l.ch == '.' && l.peekChar() != '.' {
 			numType = token.FLOAT
 			buffer = append(buffer, l.ch)
 			l.readChar()
 		}
 	}
+	// a d suffix makes a decimal, as in 12.50d
+	if l.ch == 'd' && !isIdentifier(l.peekChar()) {
+		numType = token.DECIMAL
+		buffer = append(buffer, l.ch)
+		l.readChar()
+	}
 	return token.TokenType(numType), string(buffer)
 }
 
@@ -228,8 +298,25 @@ func (l *Lexer) readString() string {
 	return string(buffer)
 }
 
+// readBytes reads the contents of a bytes literal b"...", leaving its escapes for the parser.
+func (l *Lexer) readBytes() string {
+	buffer := make([]rune, 0)
+	for {
+		l.readChar()
+		if l.ch == '"' || l.ch == 0 {
+			break
+		}
+		buffer = append(buffer, l.ch)
+		if l.ch == '\\' && l.peekChar() != 0 {
+			l.readChar()
+			buffer = append(buffer, l.ch)
+		}
+	}
+	return string(buffer)
+}
+
 func (l *Lexer) skipComment() {
-	for l.ch != '\n' {
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
 	l.skipWhitespace()
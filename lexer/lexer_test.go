@@ -43,6 +43,9 @@ let fa = 3.141592;
 let fb = 0.003;
 let array = ["a", "b"];
 let xx = array[0];
+x |> f >> g;
+a ? b.c : d?.e ?? f?[0];
+0..10 1..=n 1.5
 `
 
 	srcHandle := token.SourceHandle(0)
@@ -193,7 +196,39 @@ let xx = array[0];
 		{token.RBRACKET, "]", srcHandle.LineInfo(29, 17)},
 		{token.SEMICOLON, ";", srcHandle.LineInfo(29, 18)},
 
-		{token.EOF, "", srcHandle.LineInfo(30, 0)},
+		{token.IDENT, "x", srcHandle.LineInfo(30, 1)},
+		{token.PIPE, "|>", srcHandle.LineInfo(30, 3)},
+		{token.IDENT, "f", srcHandle.LineInfo(30, 6)},
+		{token.COMPOSE, ">>", srcHandle.LineInfo(30, 8)},
+		{token.IDENT, "g", srcHandle.LineInfo(30, 11)},
+		{token.SEMICOLON, ";", srcHandle.LineInfo(30, 12)},
+
+		{token.IDENT, "a", srcHandle.LineInfo(31, 1)},
+		{token.QUESTION, "?", srcHandle.LineInfo(31, 3)},
+		{token.IDENT, "b", srcHandle.LineInfo(31, 5)},
+		{token.DOT, ".", srcHandle.LineInfo(31, 6)},
+		{token.IDENT, "c", srcHandle.LineInfo(31, 7)},
+		{token.COLON, ":", srcHandle.LineInfo(31, 9)},
+		{token.IDENT, "d", srcHandle.LineInfo(31, 11)},
+		{token.OPTIONAL_DOT, "?.", srcHandle.LineInfo(31, 12)},
+		{token.IDENT, "e", srcHandle.LineInfo(31, 14)},
+		{token.COALESCE, "??", srcHandle.LineInfo(31, 16)},
+		{token.IDENT, "f", srcHandle.LineInfo(31, 19)},
+		{token.QUESTION, "?", srcHandle.LineInfo(31, 20)},
+		{token.LBRACKET, "[", srcHandle.LineInfo(31, 21)},
+		{token.INT, "0", srcHandle.LineInfo(31, 22)},
+		{token.RBRACKET, "]", srcHandle.LineInfo(31, 23)},
+		{token.SEMICOLON, ";", srcHandle.LineInfo(31, 24)},
+
+		{token.INT, "0", srcHandle.LineInfo(32, 1)},
+		{token.RANGE, "..", srcHandle.LineInfo(32, 2)},
+		{token.INT, "10", srcHandle.LineInfo(32, 4)},
+		{token.INT, "1", srcHandle.LineInfo(32, 7)},
+		{token.RANGE_INCLUSIVE, "..=", srcHandle.LineInfo(32, 8)},
+		{token.IDENT, "n", srcHandle.LineInfo(32, 11)},
+		{token.FLOAT, "1.5", srcHandle.LineInfo(32, 13)},
+
+		{token.EOF, "", srcHandle.LineInfo(33, 0)},
 	}
 
 	lex := NewFromString("REPL", input)
@@ -239,3 +274,49 @@ func TestLineNumbersWithComments(t *testing.T) {
 		t.Errorf("expected %s got %s", expectedLineInfo, tok.LineInfo)
 	}
 }
+
+func TestSetBrace(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"# {not a set}", []token.TokenType{token.EOF}},
+		{"#\t{not a set}\n1", []token.TokenType{token.INT, token.EOF}},
+		{"#{1} # at the end", []token.TokenType{token.SET_BRACE, token.INT, token.RBRACE, token.EOF}},
+		// a comment written without the space is a set literal, not a comment
+		{"#{ commented out }", []token.TokenType{token.SET_BRACE, token.IDENT, token.IDENT, token.RBRACE, token.EOF}},
+		{"##{not a set}", []token.TokenType{token.EOF}},
+	}
+
+	for _, tt := range tests {
+		token.ResetForTesting()
+		l := NewFromString("REPL", tt.input)
+		for _, want := range tt.expected {
+			if tok := l.NextToken(); tok.Type != want {
+				t.Fatalf("[%q] expected %s got %s", tt.input, want, tok.Type)
+			}
+		}
+	}
+}
+
+func TestBytesLiteral(t *testing.T) {
+	token.ResetForTesting()
+	input := `b"a\"b" bb"c"`
+
+	l := NewFromString("REPL", input)
+	tests := []struct {
+		typ     token.TokenType
+		literal string
+	}{
+		{token.BYTES, `a\"b`},
+		{token.IDENT, "bb"},
+		{token.STRING, "c"},
+		{token.EOF, ""},
+	}
+	for _, want := range tests {
+		tok := l.NextToken()
+		if tok.Type != want.typ || tok.Literal != want.literal {
+			t.Fatalf("expected %s %q got %s %q", want.typ, want.literal, tok.Type, tok.Literal)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package monkey embeds the Monkey interpreter in Go programs.
+//
+//	in := monkey.New(monkey.Options{Stdout: &buf})
+//	in.SetGlobal("limit", &object.Integer{Value: 10})
+//	result, err := in.Eval(`let double = fn(x) { x * 2 }; double(limit)`)
+//
+// An Interpreter keeps its globals between calls, like the REPL.  It isn't safe for
+// concurrent use.
+package monkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/vm"
+	"slices"
+	"strings"
+)
+
+// Engine selects how programs are run.
+type Engine int
+
+const (
+	EngineVM        Engine = iota // compile to bytecode and run it on the VM
+	EngineEvaluator               // walk the syntax tree
+)
+
+type Options struct {
+	Engine Engine
+	Stdout io.Writer // defaults to os.Stdout
+	Stderr io.Writer // defaults to os.Stderr
+	Limits object.Limits
+	Policy *object.Policy
+
+	// Decimal is the precision and rounding of decimal quotients.
+	Decimal object.DecimalContext
+
+	// Builtins are the builtins programs can call.  It defaults to a new registry of the
+	// standard builtins, which Register adds to.
+	Builtins *object.Registry
+}
+
+type Interpreter struct {
+	opts Options
+
+	// the VM engine's state, threaded through compilations the way the REPL does
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+
+	// the evaluator engine's state
+	env *object.Environment
+}
+
+// Program is source compiled by an Interpreter.  It can only be run by that Interpreter.
+type Program struct {
+	in       *Interpreter
+	ast      *ast.Program
+	bytecode *compiler.Bytecode
+}
+
+func New(opts Options) *Interpreter {
+	if opts.Builtins == nil {
+		opts.Builtins = object.NewRegistry()
+	}
+	in := &Interpreter{opts: opts}
+	switch opts.Engine {
+	case EngineEvaluator:
+		in.env = object.NewEnvironment()
+	default:
+		in.symbolTable = compiler.NewSymbolTable()
+		compiler.DefineBuiltins(in.symbolTable, opts.Builtins)
+		in.constants = []object.Object{}
+		in.globals = make([]object.Object, vm.GlobalSize)
+	}
+	return in
+}
+
+// Register adds the Go function fn as a builtin called name, converting its arguments and
+// results as described by object.Registry.Register.  It's visible to programs compiled
+// afterwards.
+func (in *Interpreter) Register(name string, fn any) error {
+	err := in.opts.Builtins.Register(name, fn)
+	if err != nil || in.opts.Engine == EngineEvaluator {
+		return err
+	}
+	in.symbolTable.DefineBuiltin(slices.Index(in.opts.Builtins.Names(), name), name)
+	return nil
+}
+
+// Eval compiles and runs src, and returns the value of its last expression.
+func (in *Interpreter) Eval(src string) (object.Object, error) {
+	prog, err := in.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return in.Run(context.Background(), prog)
+}
+
+// Compile parses src and, for the VM engine, compiles it.  Globals defined by src are
+// known to the programs compiled after it.
+func (in *Interpreter) Compile(src string) (*Program, error) {
+	p := parser.New(lexer.NewFromString("input", src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parse errors:\n\t%s", strings.Join(p.Errors(), "\n\t"))
+	}
+
+	prog := &Program{in: in, ast: program}
+	if in.opts.Engine == EngineEvaluator {
+		return prog, nil
+	}
+
+	comp := compiler.NewWithState(in.symbolTable, in.constants)
+	comp.SetRegistry(in.opts.Builtins)
+	comp.SetPolicy(in.opts.Policy)
+	err := comp.Compile(program)
+	if err != nil {
+		return nil, err
+	}
+	prog.bytecode = comp.Bytecode()
+	in.constants = prog.bytecode.Constants
+	return prog, nil
+}
+
+// Run runs prog until it ends or ctx is done, and returns the value of its last expression.
+// Monkey runtime errors are returned as *object.Error.
+func (in *Interpreter) Run(ctx context.Context, prog *Program) (object.Object, error) {
+	if prog.in != in {
+		return nil, errors.New("program was compiled by another interpreter")
+	}
+
+	ctx = in.context(ctx)
+	var result object.Object
+	if in.opts.Engine == EngineEvaluator {
+		result = evaluator.EvalContext(ctx, prog.ast, in.env)
+	} else {
+		machine := vm.NewWithGlobalStore(prog.bytecode, in.globals)
+		err := machine.RunContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = machine.LastPoppedStackElem()
+	}
+
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	if result == nil || !endsWithExpression(prog.ast) {
+		// the VM leaves the last value popped by a let statement behind
+		return object.NULL, nil
+	}
+	return result, nil
+}
+
+// Call calls fn, a function value of the interpreter's programs such as one returned by
+// GetGlobal, with args, and returns its result.
+func (in *Interpreter) Call(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	ctx = in.context(ctx)
+	var result object.Object
+	if in.opts.Engine == EngineEvaluator {
+		result = evaluator.CallFunction(ctx, fn, args...)
+	} else {
+		bytecode := &compiler.Bytecode{Constants: in.constants, Builtins: in.symbolTable.Builtins()}
+		var err error
+		result, err = vm.NewWithGlobalStore(bytecode, in.globals).RunFunction(ctx, fn, args...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	return result, nil
+}
+
+// context returns ctx with the options programs run under.
+func (in *Interpreter) context(ctx context.Context) context.Context {
+	ctx = object.WithOutput(ctx, in.opts.Stdout, in.opts.Stderr)
+	ctx = object.WithPolicy(ctx, in.opts.Policy)
+	ctx = object.WithRegistry(ctx, in.opts.Builtins)
+	ctx = object.WithDecimalContext(ctx, in.opts.Decimal)
+	if in.opts.Limits != (object.Limits{}) {
+		ctx = object.WithLimits(ctx, in.opts.Limits)
+	}
+	return ctx
+}
+
+func endsWithExpression(program *ast.Program) bool {
+	n := len(program.Statements)
+	if n == 0 {
+		return false
+	}
+	_, ok := program.Statements[n-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+// SetGlobal defines name as a global, visible to programs compiled afterwards.
+func (in *Interpreter) SetGlobal(name string, value object.Object) {
+	if in.opts.Engine == EngineEvaluator {
+		in.env.Set(name, value)
+		return
+	}
+	sym, ok := in.symbolTable.Resolve(name)
+	if !ok || sym.Scope != compiler.GlobalScope {
+		sym = in.symbolTable.Define(name)
+	}
+	in.globals[sym.Index] = value
+}
+
+// GetGlobal returns the value of the global name.
+func (in *Interpreter) GetGlobal(name string) (object.Object, bool) {
+	if in.opts.Engine == EngineEvaluator {
+		return in.env.Get(name)
+	}
+	sym, ok := in.symbolTable.Resolve(name)
+	if !ok || sym.Scope != compiler.GlobalScope {
+		return nil, false
+	}
+	value := in.globals[sym.Index]
+	return value, value != nil
+}
@@ -0,0 +1,205 @@
+package monkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"monkey/object"
+	"strings"
+	"testing"
+	"time"
+)
+
+var engines = []struct {
+	name   string
+	engine Engine
+}{
+	{"vm", EngineVM},
+	{"evaluator", EngineEvaluator},
+}
+
+func TestInterpreterEval(t *testing.T) {
+	tests := []struct {
+		inputs   []string
+		expected string
+	}{
+		{[]string{"1 + 2"}, "3"},
+		{[]string{"let x = 5;", "let double = fn(a) { a * 2 };", "double(x)"}, "10"},
+		{[]string{"let x = 5;"}, "null"},
+		{[]string{"let f = fn(a) { fn() { a } };", "let g = f(\"closed\");", "g()"}, "closed"},
+		{[]string{"len(\"four\")"}, "4"},
+	}
+
+	for _, e := range engines {
+		for _, tt := range tests {
+			in := New(Options{Engine: e.engine})
+			var result object.Object
+			var err error
+			for _, input := range tt.inputs {
+				result, err = in.Eval(input)
+				if err != nil {
+					t.Fatalf("%s: [%s]: unexpected error: %s", e.name, input, err)
+				}
+			}
+			if result.Inspect() != tt.expected {
+				t.Errorf("%s: %v: wrong result. want=%s, got=%s", e.name, tt.inputs, tt.expected, result.Inspect())
+			}
+		}
+	}
+}
+
+func TestInterpreterGlobals(t *testing.T) {
+	for _, e := range engines {
+		in := New(Options{Engine: e.engine})
+		in.SetGlobal("limit", &object.Integer{Value: 10})
+		_, err := in.Eval("let doubled = limit * 2;")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		doubled, ok := in.GetGlobal("doubled")
+		if !ok {
+			t.Fatalf("%s: global doubled not found", e.name)
+		}
+		if doubled.Inspect() != "20" {
+			t.Errorf("%s: wrong value for doubled. got=%s", e.name, doubled.Inspect())
+		}
+
+		in.SetGlobal("doubled", &object.Integer{Value: 1})
+		result, err := in.Eval("doubled")
+		if err != nil || result.Inspect() != "1" {
+			t.Errorf("%s: SetGlobal did not replace doubled. got=%v, %v", e.name, result, err)
+		}
+
+		if _, ok := in.GetGlobal("missing"); ok {
+			t.Errorf("%s: found undefined global", e.name)
+		}
+		if _, ok := in.GetGlobal("len"); ok {
+			t.Errorf("%s: builtin returned as a global", e.name)
+		}
+	}
+}
+
+func TestInterpreterOutput(t *testing.T) {
+	for _, e := range engines {
+		var stdout, stderr bytes.Buffer
+		in := New(Options{Engine: e.engine, Stdout: &stdout, Stderr: &stderr})
+		_, err := in.Eval(`puts("hello", 1); exec("sh -c echo")`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if stdout.String() != "hello\n1\n" {
+			t.Errorf("%s: wrong output. got=%q", e.name, stdout.String())
+		}
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	for _, e := range engines {
+		in := New(Options{Engine: e.engine})
+		if _, err := in.Eval("let = 1"); err == nil {
+			t.Errorf("%s: expected a parse error", e.name)
+		}
+
+		_, err := in.Eval(`len(1)`)
+		var errObj *object.Error
+		if !errors.As(err, &errObj) || errObj.Message != "argument to 'len' not supported, got INTEGER" {
+			t.Errorf("%s: wrong runtime error. got=%v", e.name, err)
+		}
+
+		prog, err := New(Options{Engine: e.engine}).Compile("1")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		if _, err := in.Run(context.Background(), prog); err == nil {
+			t.Errorf("%s: ran a program compiled by another interpreter", e.name)
+		}
+	}
+}
+
+func TestInterpreterRunOptions(t *testing.T) {
+	fib := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(30)"
+	for _, e := range engines {
+		in := New(Options{Engine: e.engine})
+		prog, err := in.Compile(fib)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err = in.Run(ctx, prog)
+		cancel()
+		if !errors.Is(err, object.ErrDeadline) {
+			t.Errorf("%s: expected a deadline error, got=%v", e.name, err)
+		}
+
+		in = New(Options{Engine: e.engine, Limits: object.Limits{MaxCallDepth: 10}})
+		_, err = in.Eval(fib)
+		var exceeded *object.LimitExceeded
+		if !errors.As(err, &exceeded) || exceeded.Limit != "MaxCallDepth" {
+			t.Errorf("%s: expected a call depth error, got=%v", e.name, err)
+		}
+
+		in = New(Options{Engine: e.engine, Policy: object.SandboxPolicy()})
+		_, err = in.Eval(`exec("ls")`)
+		if !errors.Is(err, object.ErrDenied) {
+			t.Errorf("%s: expected a policy error, got=%v", e.name, err)
+		}
+	}
+}
+
+func TestInterpreterRegister(t *testing.T) {
+	for _, e := range engines {
+		in := New(Options{Engine: e.engine})
+		err := in.Register("join", func(sep string, parts ...string) string {
+			return strings.Join(parts, sep)
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		result, err := in.Eval(`join("-", "a", "b") + join(",")`)
+		if err != nil || result.Inspect() != "a-b" {
+			t.Errorf("%s: wrong result. got=%v, %v", e.name, result, err)
+		}
+		if err := in.Register("bad", 1); err == nil {
+			t.Errorf("%s: registered a non-function", e.name)
+		}
+	}
+}
+
+func TestInterpreterCall(t *testing.T) {
+	for _, e := range engines {
+		in := New(Options{Engine: e.engine})
+		err := in.Register("apply", func(ctx context.Context, f object.Object, x int) object.Object {
+			return object.CallFunction(ctx, f, &object.Integer{Value: int64(x)})
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+		_, err = in.Eval(`let scale = 10; let f = fn(x) { apply(fn(y) { y * scale }, x) };`)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", e.name, err)
+		}
+
+		f, _ := in.GetGlobal("f")
+		result, err := in.Call(context.Background(), f, &object.Integer{Value: 4})
+		if err != nil || result.Inspect() != "40" {
+			t.Errorf("%s: wrong result. got=%v, %v", e.name, result, err)
+		}
+
+		_, err = in.Call(context.Background(), f, object.TRUE)
+		if err == nil {
+			t.Errorf("%s: expected an error calling f(true)", e.name)
+		}
+	}
+}
+
+func TestInterpreterDecimal(t *testing.T) {
+	for _, e := range engines {
+		in := New(Options{Engine: e.engine, Decimal: object.DecimalContext{Precision: 4, Rounding: object.RoundDown}})
+		result, err := in.Eval("2d / 3 + round(1.25d, 1)")
+		if err != nil || result.Inspect() != "1.8666" {
+			t.Errorf("%s: wrong result. got=%v, %v", e.name, result, err)
+		}
+	}
+}
@@ -3,9 +3,14 @@ package object
 import (
 	"cmp"
 	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"math"
 	"os/exec"
+	"slices"
 	"strings"
+	"unicode/utf8"
 )
 
 var (
@@ -24,11 +29,74 @@ var Builtins = []struct {
 	{"last", &Builtin{Fn: last}},
 	{"rest", &Builtin{Fn: rest}},
 	{"push", &Builtin{Fn: push}},
-	{"exec", &Builtin{Fn: execFn}},
+	{"exec", &Builtin{Fn: execFn, Requires: CapProcess}},
 	{"cmp", &Builtin{Fn: cmpFn}},
+	{"collect", &Builtin{Fn: collect}},
+	{"chan", &Builtin{Fn: chanFn}},
+	{"select", &Builtin{Fn: selectFn}},
+	{"map", &Builtin{Fn: mapFn}},
+	{"filter", &Builtin{Fn: filter}},
+	{"reduce", &Builtin{Fn: reduce}},
+	{"sort", &Builtin{Fn: sortFn}},
+	{"find", &Builtin{Fn: find}},
+	{"any", &Builtin{Fn: anyFn}},
+	{"all", &Builtin{Fn: all}},
+	{"zip", &Builtin{Fn: zip}},
+	{"enumerate", &Builtin{Fn: enumerate}},
+	{"flatten", &Builtin{Fn: flatten}},
+	{"unique", &Builtin{Fn: unique}},
+	{"strings", stringsNamespace},
+	{"int", &Builtin{Fn: intFn}},
+	{"float", &Builtin{Fn: floatFn}},
+	{"str", &Builtin{Fn: str}},
+	{"parse_int", &Builtin{Fn: parseIntFn}},
+	{"parse_float", &Builtin{Fn: parseFloatFn}},
+	{"abs", &Builtin{Fn: abs}},
+	{"min", &Builtin{Fn: minFn}},
+	{"max", &Builtin{Fn: maxFn}},
+	{"floor", &Builtin{Fn: rounder("floor", math.Floor, RoundFloor)}},
+	{"ceil", &Builtin{Fn: rounder("ceil", math.Ceil, RoundCeiling)}},
+	{"round", &Builtin{Fn: roundFn}},
+	{"sqrt", &Builtin{Fn: floatFunc("sqrt", math.Sqrt)}},
+	{"pow", &Builtin{Fn: pow}},
+	{"log", &Builtin{Fn: logFn}},
+	{"sin", &Builtin{Fn: floatFunc("sin", math.Sin)}},
+	{"cos", &Builtin{Fn: floatFunc("cos", math.Cos)}},
+	{"tan", &Builtin{Fn: floatFunc("tan", math.Tan)}},
+	{"asin", &Builtin{Fn: floatFunc("asin", math.Asin)}},
+	{"acos", &Builtin{Fn: floatFunc("acos", math.Acos)}},
+	{"atan", &Builtin{Fn: floatFunc("atan", math.Atan)}},
+	{"atan2", &Builtin{Fn: atan2}},
+	{"PI", piConstant},
+	{"E", eConstant},
+	{"decimal", &Builtin{Fn: decimal}},
+	{"keys", &Builtin{Fn: keys}},
+	{"values", &Builtin{Fn: values}},
+	{"items", &Builtin{Fn: items}},
+	{"has", &Builtin{Fn: has}},
+	{"get", &Builtin{Fn: get}},
+	{"delete", &Builtin{Fn: deleteFn}},
+	{"merge", &Builtin{Fn: merge}},
+	{"set", &Builtin{Fn: setFn}},
+	{"add", &Builtin{Fn: add}},
+	{"remove", &Builtin{Fn: remove}},
+	{"union", &Builtin{Fn: union}},
+	{"intersection", &Builtin{Fn: intersection}},
+	{"difference", &Builtin{Fn: difference}},
+	{"bytes", &Builtin{Fn: bytesFn}},
+	{"hex_encode", &Builtin{Fn: encoder("hex_encode", hex.EncodeToString)}},
+	{"hex_decode", &Builtin{Fn: decoder("hex_decode", hex.DecodeString)}},
+	{"base64_encode", &Builtin{Fn: encoder("base64_encode", base64.StdEncoding.EncodeToString)}},
+	{"base64_decode", &Builtin{Fn: decoder("base64_decode", base64.StdEncoding.DecodeString)}},
+	{"exec_bytes", &Builtin{Fn: execBytes, Requires: CapProcess}},
+	{"read_file", &Builtin{Fn: readFile, Requires: CapFilesystem}},
+	{"read_bytes", &Builtin{Fn: readBytes, Requires: CapFilesystem}},
+	{"write_file", &Builtin{Fn: writeFile, Requires: CapFilesystem}},
+	{"json_parse", &Builtin{Fn: jsonParse}},
+	{"json_stringify", &Builtin{Fn: jsonStringify}},
 }
 
-func length(args ...Object) Object {
+func length(ctx context.Context, args ...Object) Object {
 	if len(args) != 1 {
 		return newError("wrong number of arguments, got=%d, want=1", len(args))
 	}
@@ -36,22 +104,40 @@ func length(args ...Object) Object {
 	case *Array:
 		return &Integer{Value: int64(len(arg.Elements))}
 	case *String:
-		return &Integer{Value: int64(len(arg.Value))}
+		return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 	case *Hash:
-		return &Integer{Value: int64(len(arg.Pairs))}
+		return &Integer{Value: int64(arg.Len())}
+	case *Set:
+		return &Integer{Value: int64(arg.Len())}
+	case *Tuple:
+		return &Integer{Value: int64(len(arg.Elements))}
+	case *Bytes:
+		return &Integer{Value: int64(len(arg.Value))}
+	case *Range:
+		n, ok := arg.Len()
+		if !ok {
+			return newError("length of %s doesn't fit in an INTEGER", arg.Inspect())
+		}
+		return &Integer{Value: n}
 	default:
 		return newError("argument to 'len' not supported, got %s", args[0].Type())
 	}
 }
 
-func puts(args ...Object) Object {
+func puts(ctx context.Context, args ...Object) Object {
+	budget := BudgetFromContext(ctx)
+	stdout := Stdout(ctx)
 	for _, v := range args {
-		fmt.Println(v.Inspect())
+		out := v.Inspect()
+		if err := budget.Output(len(out) + 1); err != nil {
+			return err
+		}
+		fmt.Fprintln(stdout, out)
 	}
 	return NULL
 }
 
-func first(args ...Object) Object {
+func first(ctx context.Context, args ...Object) Object {
 	if err, ok := checkArray("first", args); !ok {
 		return err
 	}
@@ -62,7 +148,7 @@ func first(args ...Object) Object {
 	return NULL
 }
 
-func last(args ...Object) Object {
+func last(ctx context.Context, args ...Object) Object {
 	if err, ok := checkArray("last", args); !ok {
 		return err
 	}
@@ -73,7 +159,7 @@ func last(args ...Object) Object {
 	return NULL
 }
 
-func rest(args ...Object) Object {
+func rest(ctx context.Context, args ...Object) Object {
 	if err, ok := checkArray("rest", args); !ok {
 		return err
 	}
@@ -82,12 +168,12 @@ func rest(args ...Object) Object {
 	if count > 0 {
 		elems := make([]Object, count-1)
 		copy(elems, arr.Elements[1:])
-		return &Array{Elements: elems}
+		return allocated(ctx, &Array{Elements: elems})
 	}
-	return &Array{Elements: make([]Object, 0)}
+	return allocated(ctx, &Array{Elements: make([]Object, 0)})
 }
 
-func push(args ...Object) Object {
+func push(ctx context.Context, args ...Object) Object {
 	if len(args) != 2 {
 		return newError("wrong number of arguments. got=%d, want=2", len(args))
 	}
@@ -97,7 +183,7 @@ func push(args ...Object) Object {
 
 	arr := args[0].(*Array)
 	newElements := append(arr.Elements, args[1])
-	return &Array{Elements: newElements}
+	return allocated(ctx, &Array{Elements: newElements})
 }
 
 func checkArray(name string, args []Object) (Object, bool) {
@@ -111,27 +197,48 @@ func checkArray(name string, args []Object) (Object, bool) {
 	return nil, true
 }
 
-func execFn(args ...Object) Object {
-	if args[0].Type() != STRING_OBJ {
-		return newError("exec requires a string argument")
+func execFn(ctx context.Context, args ...Object) Object {
+	out, err := runCommand(ctx, args)
+	if err != nil {
+		return err
+	}
+	return allocated(ctx, &String{Value: string(out)})
+}
+
+// execBytes is exec returning stdout as bytes: exec_bytes(cmd).
+func execBytes(ctx context.Context, args ...Object) Object {
+	out, err := runCommand(ctx, args)
+	if err != nil {
+		return err
+	}
+	return allocated(ctx, &Bytes{Value: out})
+}
+
+// runCommand runs the command in args[0], split on spaces, and returns its stdout.
+func runCommand(ctx context.Context, args []Object) ([]byte, Object) {
+	if len(args) == 0 || args[0].Type() != STRING_OBJ {
+		return nil, newError("exec requires a string argument")
 	}
 	strObj := args[0].(*String)
 	parts := strings.Split(strObj.Value, " ")
-	cmd := exec.CommandContext(context.Background(), parts[0], parts[1:]...)
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	cmd.Stderr = Stderr(ctx)
 	err := cmd.Err
 	if err != nil {
-		return newError("%+v", err)
+		return nil, newError("%+v", err)
 	}
 
 	out, err := cmd.Output()
 	if err != nil {
-		return newError("exec failed: %+v", err)
+		if ctx.Err() != nil {
+			return nil, NewInterruptError(ctx)
+		}
+		return nil, newError("exec failed: %+v", err)
 	}
-
-	return &String{Value: string(out)}
+	return out, nil
 }
 
-func cmpFn(args ...Object) Object {
+func cmpFn(ctx context.Context, args ...Object) Object {
 	if len(args) != 2 {
 		return newError("cmp requires 2 arguments")
 	}
@@ -164,6 +271,78 @@ func cmpFn(args ...Object) Object {
 	}
 }
 
+// collect copies the values of an iterable, such as a range, into a new array.
+func collect(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	if _, ok := args[0].(Iterable); !ok {
+		return newError("argument to 'collect' must be iterable, got %s", args[0].Type())
+	}
+	values, err := iterableValues(ctx, "collect", args[0])
+	if err != nil {
+		return err
+	}
+	return allocated(ctx, &Array{Elements: slices.Clone(values)})
+}
+
+// chanFn creates a channel, unbuffered unless a capacity is given.
+func chanFn(ctx context.Context, args ...Object) Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+	sched := SchedulerFromContext(ctx)
+	if len(args) == 0 {
+		return sched.NewChannel(0)
+	}
+	capacity, ok := args[0].(*Integer)
+	if !ok {
+		return newError("argument to 'chan' must be INTEGER, got %s", args[0].Type())
+	}
+	if capacity.Value < 0 {
+		return newError("negative channel capacity: %d", capacity.Value)
+	}
+	return sched.NewChannel(int(capacity.Value))
+}
+
+// selectFn waits on an array of cases: a channel to receive from, or a [channel, value]
+// pair to send.  It returns [index, value] for the case that proceeded.
+func selectFn(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	arr, ok := args[0].(*Array)
+	if !ok || len(arr.Elements) == 0 {
+		return newError("argument to 'select' must be a non-empty ARRAY of cases, got %s", args[0].Inspect())
+	}
+
+	cases := make([]SelectCase, len(arr.Elements))
+	for i, el := range arr.Elements {
+		switch el := el.(type) {
+		case *Channel:
+			cases[i] = SelectCase{Channel: el}
+		case *Array:
+			var ch *Channel
+			ok := false
+			if len(el.Elements) == 2 {
+				ch, ok = el.Elements[0].(*Channel)
+			}
+			if !ok {
+				return newError("select case %d must be a CHANNEL or [CHANNEL, value], got %s", i, el.Inspect())
+			}
+			cases[i] = SelectCase{Channel: ch, Send: true, Value: el.Elements[1]}
+		default:
+			return newError("select case %d must be a CHANNEL or [CHANNEL, value], got %s", i, el.Type())
+		}
+	}
+
+	index, value, err := Select(ctx, cases)
+	if err != nil {
+		return err
+	}
+	return &Array{Elements: []Object{&Integer{Value: int64(index)}, value}}
+}
+
 func newError(format string, a ...interface{}) *Error {
 	return &Error{Message: fmt.Sprintf(format, a...)}
 }
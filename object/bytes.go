@@ -0,0 +1,189 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"unicode/utf8"
+)
+
+// Bytes is an immutable sequence of bytes, written b"...", for binary data and text that
+// isn't valid UTF-8.  Indexing it gives integers from 0 to 255.
+type Bytes struct {
+	Value []byte
+}
+
+func (b *Bytes) Type() ObjectType { return BYTES_OBJ }
+
+// Inspect prints b as a literal, escaping quotes, backslashes and bytes that aren't printable
+// ASCII: b"GIF89a\x01\x00".
+func (b *Bytes) Inspect() string {
+	var out bytes.Buffer
+	out.WriteString(`b"`)
+	for _, c := range b.Value {
+		switch {
+		case c == '"' || c == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case c == '\n':
+			out.WriteString(`\n`)
+		case c == '\r':
+			out.WriteString(`\r`)
+		case c == '\t':
+			out.WriteString(`\t`)
+		case c < 0x20 || c > 0x7e:
+			fmt.Fprintf(&out, `\x%02x`, c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteString(`"`)
+	return out.String()
+}
+
+func (b *Bytes) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(b.Value)
+	return HashKey{Type: BYTES_OBJ, Value: h.Sum64()}
+}
+
+// BytesIndex returns the byte at idx as an integer.  It returns false when idx is out of
+// range.
+func BytesIndex(b *Bytes, idx int64) (*Integer, bool) {
+	i, ok := ResolveIndex(idx, len(b.Value))
+	if !ok {
+		return nil, false
+	}
+	return &Integer{Value: int64(b.Value[i])}, true
+}
+
+// bytesFn converts a string to its UTF-8 bytes, or an array of integers from 0 to 255 to
+// bytes: bytes(x).
+func bytesFn(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *Bytes:
+		return arg
+	case *String:
+		return allocated(ctx, &Bytes{Value: []byte(arg.Value)})
+	case *Array:
+		value := make([]byte, len(arg.Elements))
+		for i, el := range arg.Elements {
+			n, ok := el.(*Integer)
+			if !ok || n.Value < 0 || n.Value > 255 {
+				return newError("elements of the array passed to 'bytes' must be integers from 0 to 255, got %s", el.Inspect())
+			}
+			value[i] = byte(n.Value)
+		}
+		return allocated(ctx, &Bytes{Value: value})
+	default:
+		return newError("argument to 'bytes' not supported, got %s", args[0].Type())
+	}
+}
+
+// bytesToString decodes b as UTF-8, for str(b).
+func bytesToString(ctx context.Context, b *Bytes) Object {
+	if !utf8.Valid(b.Value) {
+		return newError("BYTES passed to 'str' aren't valid UTF-8")
+	}
+	return allocated(ctx, &String{Value: string(b.Value)})
+}
+
+// bytesArg returns the bytes of a BYTES or STRING argument of builtin name.
+func bytesArg(name string, arg Object) ([]byte, Object) {
+	switch arg := arg.(type) {
+	case *Bytes:
+		return arg.Value, nil
+	case *String:
+		return []byte(arg.Value), nil
+	}
+	return nil, newError("argument to '%s' must be BYTES or STRING, got %s", name, arg.Type())
+}
+
+// encoder returns a builtin encoding bytes, or the UTF-8 bytes of a string, as a string.
+func encoder(name string, encode func([]byte) string) BuiltinFunction {
+	return func(ctx context.Context, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		value, err := bytesArg(name, args[0])
+		if err != nil {
+			return err
+		}
+		return allocated(ctx, &String{Value: encode(value)})
+	}
+}
+
+// decoder returns a builtin decoding a string to bytes.
+func decoder(name string, decode func(string) ([]byte, error)) BuiltinFunction {
+	return func(ctx context.Context, args ...Object) Object {
+		if err := checkArgs(name, args, 1, STRING_OBJ); err != nil {
+			return err
+		}
+		value, err := decode(args[0].(*String).Value)
+		if err != nil {
+			return newError("'%s' failed: %s", name, err)
+		}
+		return allocated(ctx, &Bytes{Value: value})
+	}
+}
+
+// readFile returns the contents of a file as a string: read_file(path).
+func readFile(ctx context.Context, args ...Object) Object {
+	data, err := readPath(ctx, "read_file", args)
+	if err != nil {
+		return err
+	}
+	return allocated(ctx, &String{Value: string(data)})
+}
+
+// readBytes returns the contents of a file as bytes: read_bytes(path).
+func readBytes(ctx context.Context, args ...Object) Object {
+	data, err := readPath(ctx, "read_bytes", args)
+	if err != nil {
+		return err
+	}
+	return allocated(ctx, &Bytes{Value: data})
+}
+
+func readPath(ctx context.Context, name string, args []Object) ([]byte, Object) {
+	if err := checkArgs(name, args, 1, STRING_OBJ); err != nil {
+		return nil, err
+	}
+	path := args[0].(*String).Value
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+	if !BudgetFromContext(ctx).fits(info.Size()) {
+		return nil, newError("'%s' of %s: file too large", name, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newError("%s", err)
+	}
+	return data, nil
+}
+
+// writeFile writes a string or bytes to a file, replacing its contents: write_file(path, data).
+func writeFile(ctx context.Context, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	path, ok := args[0].(*String)
+	if !ok {
+		return newError("argument 1 to 'write_file' must be STRING, got %s", args[0].Type())
+	}
+	data, errObj := bytesArg("write_file", args[1])
+	if errObj != nil {
+		return errObj
+	}
+	if err := os.WriteFile(path.Value, data, 0o666); err != nil {
+		return newError("%s", err)
+	}
+	return NULL
+}
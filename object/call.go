@@ -0,0 +1,78 @@
+package object
+
+import "context"
+
+// Caller calls the functions of a running program.  The VM and the evaluator each put one in
+// the context they pass builtins, so builtins can call the functions they're passed.
+type Caller interface {
+	// CallFunction calls fn with args and returns its result.  It must be called by the
+	// builtin's goroutine before the builtin returns.
+	CallFunction(ctx context.Context, fn Object, args ...Object) Object
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context whose builtins call functions with c.
+func WithCaller(ctx context.Context, c Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// CallFunction calls fn, a function or builtin of the running program, with args, from a
+// builtin.  An error that stops the program is returned as an *Error with a Cause; the
+// builtin should return it.
+func CallFunction(ctx context.Context, fn Object, args ...Object) Object {
+	call, err := callable(ctx, "CallFunction", fn)
+	if err != nil {
+		return err
+	}
+	return call(args...)
+}
+
+// callable returns a function calling fn, for builtin name to call it repeatedly.
+func callable(ctx context.Context, name string, fn Object) (func(...Object) Object, Object) {
+	switch fn := fn.(type) {
+	case *Builtin:
+		return func(args ...Object) Object {
+			result := fn.Fn(ctx, args...)
+			if result == nil {
+				return NULL
+			}
+			return result
+		}, nil
+	case *Composition:
+		first, err := callable(ctx, name, fn.First)
+		if err != nil {
+			return nil, err
+		}
+		second, err := callable(ctx, name, fn.Second)
+		if err != nil {
+			return nil, err
+		}
+		return func(args ...Object) Object {
+			result := first(args...)
+			if isError(result) {
+				return result
+			}
+			return second(result)
+		}, nil
+	case *RecordType:
+		return func(args ...Object) Object {
+			record, err := fn.New(args)
+			if err != nil {
+				return newError("%s", err)
+			}
+			return record
+		}, nil
+	case *Function, *Closure:
+	default:
+		return nil, newError("argument to '%s' must be a function, got %s", name, fn.Type())
+	}
+
+	c, ok := ctx.Value(callerKey{}).(Caller)
+	if !ok {
+		return nil, newError("can't call %s outside a running program", fn.Type())
+	}
+	return func(args ...Object) Object {
+		return c.CallFunction(ctx, fn, args...)
+	}, nil
+}
@@ -0,0 +1,237 @@
+package object
+
+import (
+	"context"
+	"fmt"
+)
+
+// channelEntry is a waiter queued on a channel, with the select case it's queued for and,
+// for a sender, the value to send.
+type channelEntry struct {
+	w         *waiter
+	caseIndex int
+	value     Object
+}
+
+// Channel passes values between tasks.  An unbuffered channel hands each value directly from
+// a sender to a receiver; a buffered one holds up to capacity values.
+type Channel struct {
+	sched    *Scheduler
+	capacity int
+	buffer   []Object
+	closed   bool
+	recvq    []channelEntry
+	sendq    []channelEntry
+}
+
+// NewChannel returns a channel for Go code outside of any task.
+func NewChannel(capacity int) *Channel {
+	return detached.NewChannel(capacity)
+}
+
+// NewChannel returns a channel for the tasks scheduled by s.
+func (s *Scheduler) NewChannel(capacity int) *Channel {
+	return &Channel{sched: s, capacity: capacity}
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string  { return fmt.Sprintf("channel(%d)", c.capacity) }
+
+// dequeue pops the first waiter that a select hasn't already completed elsewhere.
+func dequeue(q *[]channelEntry) (channelEntry, bool) {
+	for len(*q) > 0 {
+		e := (*q)[0]
+		*q = (*q)[1:]
+		if !e.w.fired {
+			return e, true
+		}
+	}
+	return channelEntry{}, false
+}
+
+// trySend completes a send without blocking if a receiver is waiting or the buffer has
+// room.  The scheduler lock must be held.
+func (c *Channel) trySend(value Object) (bool, *Error) {
+	if c.closed {
+		return false, newError("send on closed channel")
+	}
+	if e, ok := dequeue(&c.recvq); ok {
+		e.w.value, e.w.ok, e.w.caseIndex = value, true, e.caseIndex
+		c.sched.fire(e.w)
+		return true, nil
+	}
+	if len(c.buffer) < c.capacity {
+		c.buffer = append(c.buffer, value)
+		return true, nil
+	}
+	return false, nil
+}
+
+// tryRecv completes a receive without blocking if a value is buffered, a sender is waiting
+// or the channel is closed.  The scheduler lock must be held.
+func (c *Channel) tryRecv() (value Object, ok bool, ready bool) {
+	if len(c.buffer) > 0 {
+		value = c.buffer[0]
+		c.buffer = c.buffer[1:]
+		if e, found := dequeue(&c.sendq); found {
+			c.buffer = append(c.buffer, e.value)
+			e.w.caseIndex = e.caseIndex
+			c.sched.fire(e.w)
+		}
+		return value, true, true
+	}
+	if e, found := dequeue(&c.sendq); found {
+		value = e.value
+		e.w.caseIndex = e.caseIndex
+		c.sched.fire(e.w)
+		return value, true, true
+	}
+	if c.closed {
+		return NULL, false, true
+	}
+	return nil, false, false
+}
+
+// Send blocks the task calling with ctx until value is received or buffered.
+func (c *Channel) Send(ctx context.Context, value Object) *Error {
+	c.sched.mu.Lock()
+	if done, err := c.trySend(value); done || err != nil {
+		c.sched.mu.Unlock()
+		return err
+	}
+
+	w := newWaiter(TaskFromContext(ctx), "send")
+	c.sendq = append(c.sendq, channelEntry{w: w, value: value})
+	c.sched.park(ctx, w)
+	return w.err
+}
+
+// Recv blocks the task calling with ctx until a value is available.  ok is false once the
+// channel is closed and drained.
+func (c *Channel) Recv(ctx context.Context) (Object, bool, *Error) {
+	c.sched.mu.Lock()
+	if value, ok, ready := c.tryRecv(); ready {
+		c.sched.mu.Unlock()
+		return value, ok, nil
+	}
+
+	w := newWaiter(TaskFromContext(ctx), "recv")
+	c.recvq = append(c.recvq, channelEntry{w: w})
+	c.sched.park(ctx, w)
+	if w.err != nil {
+		return nil, false, w.err
+	}
+	return w.value, w.ok, nil
+}
+
+// Close wakes every waiting receiver with NULL and fails every waiting sender.
+func (c *Channel) Close() *Error {
+	c.sched.mu.Lock()
+	defer c.sched.mu.Unlock()
+	if c.closed {
+		return newError("close of closed channel")
+	}
+	c.closed = true
+	for e, ok := dequeue(&c.recvq); ok; e, ok = dequeue(&c.recvq) {
+		e.w.value, e.w.ok, e.w.caseIndex = NULL, false, e.caseIndex
+		c.sched.fire(e.w)
+	}
+	for e, ok := dequeue(&c.sendq); ok; e, ok = dequeue(&c.sendq) {
+		e.w.err = newError("send on closed channel")
+		c.sched.fire(e.w)
+	}
+	return nil
+}
+
+// SelectCase is one operation of a select: a receive from Channel, or a send of Value when Send is set.
+type SelectCase struct {
+	Channel *Channel
+	Send    bool
+	Value   Object
+}
+
+// Select blocks the task calling with ctx until one of the cases can proceed, and returns
+// its index together with the value received, or NULL for a send.  Ready cases are tried in order.
+func Select(ctx context.Context, cases []SelectCase) (int, Object, *Error) {
+	if len(cases) == 0 {
+		return 0, nil, newError("select with no cases")
+	}
+	// channel state is guarded by the lock of the scheduler the channels were made by
+	sched := cases[0].Channel.sched
+	for _, sc := range cases[1:] {
+		if sc.Channel.sched != sched {
+			return 0, nil, newError("select on channels of different programs")
+		}
+	}
+	sched.mu.Lock()
+	for i, sc := range cases {
+		if sc.Send {
+			done, err := sc.Channel.trySend(sc.Value)
+			if err != nil {
+				sched.mu.Unlock()
+				return i, nil, err
+			}
+			if done {
+				sched.mu.Unlock()
+				return i, NULL, nil
+			}
+		} else if value, _, ready := sc.Channel.tryRecv(); ready {
+			sched.mu.Unlock()
+			return i, value, nil
+		}
+	}
+
+	w := newWaiter(TaskFromContext(ctx), "select")
+	for i, sc := range cases {
+		if sc.Send {
+			sc.Channel.sendq = append(sc.Channel.sendq, channelEntry{w: w, caseIndex: i, value: sc.Value})
+		} else {
+			sc.Channel.recvq = append(sc.Channel.recvq, channelEntry{w: w, caseIndex: i})
+		}
+	}
+	sched.park(ctx, w)
+	if w.err != nil {
+		return w.caseIndex, nil, w.err
+	}
+	if cases[w.caseIndex].Send {
+		return w.caseIndex, NULL, nil
+	}
+	return w.caseIndex, w.value, nil
+}
+
+func (c *Channel) Method(name string) (*Builtin, bool) {
+	switch name {
+	case "send":
+		return &Builtin{Fn: func(ctx context.Context, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if err := c.Send(ctx, args[0]); err != nil {
+				return err
+			}
+			return NULL
+		}}, true
+	case "recv":
+		return &Builtin{Fn: func(ctx context.Context, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			value, _, err := c.Recv(ctx)
+			if err != nil {
+				return err
+			}
+			return value
+		}}, true
+	case "close":
+		return &Builtin{Fn: func(ctx context.Context, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			if err := c.Close(); err != nil {
+				return err
+			}
+			return NULL
+		}}, true
+	}
+	return nil, false
+}
@@ -0,0 +1,445 @@
+package object
+
+import (
+	"cmp"
+	"context"
+	"slices"
+)
+
+// The collection builtins take an array or any other iterable, and call the functions they're
+// given with object.CallFunction.  They return new arrays, leaving their arguments unchanged.
+// They stream the values of an iterable, so find, any and all stop as soon as they know their
+// answer, and only builtins returning an array hold every value.
+
+// cancelCheckInterval is how many values are iterated between checks for cancellation.
+const cancelCheckInterval = 1024
+
+// mapFn returns the results of calling f with each value: map(xs, f).
+func mapFn(ctx context.Context, args ...Object) Object {
+	xs, call, err := iterateWith(ctx, "map", args)
+	if err != nil {
+		return err
+	}
+	results := newArrayBuilder(ctx)
+	stopped := each(ctx, xs, func(value Object) Object {
+		result := call(value)
+		if isError(result) {
+			return result
+		}
+		return results.add(result)
+	})
+	if stopped != nil {
+		return stopped
+	}
+	return allocated(ctx, &Array{Elements: results.values})
+}
+
+// filter returns the values for which f is truthy: filter(xs, f).
+func filter(ctx context.Context, args ...Object) Object {
+	xs, call, err := iterateWith(ctx, "filter", args)
+	if err != nil {
+		return err
+	}
+	results := newArrayBuilder(ctx)
+	stopped := each(ctx, xs, func(value Object) Object {
+		result := call(value)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			return results.add(value)
+		}
+		return nil
+	})
+	if stopped != nil {
+		return stopped
+	}
+	return allocated(ctx, &Array{Elements: results.values})
+}
+
+// find returns the first value for which f is truthy, or null: find(xs, f).
+func find(ctx context.Context, args ...Object) Object {
+	xs, call, err := iterateWith(ctx, "find", args)
+	if err != nil {
+		return err
+	}
+	found := each(ctx, xs, func(value Object) Object {
+		result := call(value)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) {
+			return value
+		}
+		return nil
+	})
+	if found == nil {
+		return NULL
+	}
+	return found
+}
+
+// anyFn reports whether f, or the value itself if there's no f, is truthy for any value:
+// any(xs, f?).
+func anyFn(ctx context.Context, args ...Object) Object {
+	return quantify(ctx, "any", true, args)
+}
+
+// all reports whether f, or the value itself if there's no f, is truthy for every value:
+// all(xs, f?).
+func all(ctx context.Context, args ...Object) Object {
+	return quantify(ctx, "all", false, args)
+}
+
+// quantify looks for a value whose truthiness is want, returning want if there is one.
+func quantify(ctx context.Context, name string, want bool, args []Object) Object {
+	if len(args) == 1 {
+		args = append(args, nil)
+	}
+	xs, call, err := iterateWith(ctx, name, args)
+	if err != nil {
+		return err
+	}
+	found := each(ctx, xs, func(value Object) Object {
+		result := value
+		if call != nil {
+			result = call(value)
+		}
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) == want {
+			return nativeBool(want)
+		}
+		return nil
+	})
+	if found == nil {
+		return nativeBool(!want)
+	}
+	return found
+}
+
+// reduce folds the values into an accumulator with f(acc, value), starting from initial,
+// or the first value if there's no initial: reduce(xs, f, initial?).
+func reduce(ctx context.Context, args ...Object) Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+	xs, call, err := iterateWith(ctx, "reduce", args[:2])
+	if err != nil {
+		return err
+	}
+	var acc Object
+	if len(args) == 3 {
+		acc = args[2]
+	}
+	stopped := each(ctx, xs, func(value Object) Object {
+		if acc == nil {
+			acc = value
+			return nil
+		}
+		acc = call(acc, value)
+		if isError(acc) {
+			return acc
+		}
+		return nil
+	})
+	switch {
+	case stopped != nil:
+		return stopped
+	case acc == nil:
+		return newError("reduce of empty %s with no initial value", args[0].Type())
+	}
+	return acc
+}
+
+// sortFn returns the values in ascending order, or in the order of the comparator
+// f(a, b), which returns a negative integer if a comes first, a positive integer if b
+// does, and 0 if either may: sort(xs, f?).  The sort is stable.
+func sortFn(ctx context.Context, args ...Object) Object {
+	if len(args) == 1 {
+		args = append(args, nil)
+	}
+	_, call, err := iterateWith(ctx, "sort", args)
+	if err != nil {
+		return err
+	}
+	values, err := iterableValues(ctx, "sort", args[0])
+	if err != nil {
+		return err
+	}
+	sorted := slices.Clone(values)
+
+	var failed Object
+	compare := func(a, b Object) int {
+		if failed != nil {
+			return 0
+		}
+		if call == nil {
+			c, err := compareOrdered(a, b)
+			failed = err
+			return c
+		}
+		result := call(a, b)
+		n, ok := result.(*Integer)
+		switch {
+		case isError(result):
+			failed = result
+		case !ok:
+			failed = newError("comparator passed to 'sort' must return INTEGER, got %s", result.Type())
+		default:
+			return cmp.Compare(n.Value, 0)
+		}
+		return 0
+	}
+	slices.SortStableFunc(sorted, compare)
+	if failed != nil {
+		return failed
+	}
+	return allocated(ctx, &Array{Elements: sorted})
+}
+
+// compareOrdered compares a and b as < and > do.
+func compareOrdered(a, b Object) (int, Object) {
+	c, err := Compare(a, b)
+	if err != nil {
+		return 0, newError("'sort' can't order %s and %s without a comparator", a.Type(), b.Type())
+	}
+	return c, nil
+}
+
+// zip pairs up the values of its arguments, stopping at the shortest: zip(xs, ys, ...).
+func zip(ctx context.Context, args ...Object) Object {
+	if len(args) < 2 {
+		return newError("wrong number of arguments. got=%d, want at least 2", len(args))
+	}
+	iterables := make([]Iterable, len(args))
+	for i, arg := range args {
+		xs, err := iterableArg("zip", arg)
+		if err != nil {
+			return err
+		}
+		iterables[i] = xs
+	}
+	// the first iterable drives the loop, and the others are pulled alongside it
+	first, columns := iterables[0], make([]Iterator, len(args)-1)
+	for i, xs := range iterables[1:] {
+		columns[i] = xs.Iterator()
+	}
+
+	rows := newArrayBuilder(ctx)
+	exhausted := false
+	stopped := each(ctx, first, func(value Object) Object {
+		row := make([]Object, 1, len(args))
+		row[0] = value
+		for _, column := range columns {
+			value, ok := column.Next()
+			if !ok {
+				exhausted = true
+				return NULL
+			}
+			if isError(value) {
+				return value
+			}
+			row = append(row, value)
+		}
+		return rows.addAllocated(&Array{Elements: row})
+	})
+	if stopped != nil && !exhausted {
+		return stopped
+	}
+	return allocated(ctx, &Array{Elements: rows.values})
+}
+
+// enumerate pairs each value with its index: enumerate(xs).
+func enumerate(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	xs, err := iterableArg("enumerate", args[0])
+	if err != nil {
+		return err
+	}
+	pairs := newArrayBuilder(ctx)
+	stopped := each(ctx, xs, func(value Object) Object {
+		index := &Integer{Value: int64(len(pairs.values))}
+		return pairs.addAllocated(&Array{Elements: []Object{index, value}})
+	})
+	if stopped != nil {
+		return stopped
+	}
+	return allocated(ctx, &Array{Elements: pairs.values})
+}
+
+// flatten replaces arrays in the values with their elements, to the given depth, or one
+// level if there's no depth: flatten(xs, depth?).
+func flatten(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	depth := int64(1)
+	if len(args) == 2 {
+		n, ok := args[1].(*Integer)
+		if !ok {
+			return newError("depth passed to 'flatten' must be INTEGER, got %s", args[1].Type())
+		}
+		depth = n.Value
+	}
+	values, err := iterableValues(ctx, "flatten", args[0])
+	if err != nil {
+		return err
+	}
+	return allocated(ctx, &Array{Elements: flattenInto(make([]Object, 0, len(values)), values, depth)})
+}
+
+func flattenInto(dst, values []Object, depth int64) []Object {
+	for _, value := range values {
+		if arr, ok := value.(*Array); ok && depth > 0 {
+			dst = flattenInto(dst, arr.Elements, depth-1)
+		} else {
+			dst = append(dst, value)
+		}
+	}
+	return dst
+}
+
+// unique returns the values without repeats, keeping the first of each: unique(xs).
+func unique(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	values, err := iterableValues(ctx, "unique", args[0])
+	if err != nil {
+		return err
+	}
+	seen := make(map[HashKey]bool, len(values))
+	results := make([]Object, 0, len(values))
+	for _, value := range values {
+		key, ok := HashKeyOf(value)
+		if !ok {
+			return newError("unusable as hash key: %s", value.Type())
+		}
+		if !seen[key] {
+			seen[key] = true
+			results = append(results, value)
+		}
+	}
+	return allocated(ctx, &Array{Elements: results})
+}
+
+// iterateWith checks the arguments (xs, f) of a builtin, and returns xs and a function
+// calling f.  A nil f gives a nil function.
+func iterateWith(ctx context.Context, name string, args []Object) (Iterable, func(...Object) Object, Object) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	xs, err := iterableArg(name, args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if args[1] == nil {
+		return xs, nil, nil
+	}
+	call, err := callable(ctx, name, args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return xs, call, nil
+}
+
+// iterableArg returns obj, an argument to the named builtin, as an Iterable.
+func iterableArg(name string, obj Object) (Iterable, Object) {
+	xs, ok := obj.(Iterable)
+	if !ok {
+		return nil, newError("argument to '%s' must be iterable, got %s", name, obj.Type())
+	}
+	return xs, nil
+}
+
+// each calls fn with each value of xs until fn returns a result, and returns that result,
+// or nil if fn never returns one.  It stops with an error when ctx is done, so a long range
+// or an endless generator can be interrupted, or when xs fails.
+func each(ctx context.Context, xs Iterable, fn func(Object) Object) Object {
+	var result Object
+	n := 0
+	Iterate(xs, func(value Object) bool {
+		n++
+		if isError(value) {
+			result = value
+		} else if n%cancelCheckInterval == 0 && ctx.Err() != nil {
+			result = NewInterruptError(ctx)
+		} else {
+			result = fn(value)
+		}
+		return result == nil
+	})
+	return result
+}
+
+// arrayBuilder collects the elements of a new array, failing as soon as the array would
+// exceed the allocation budget rather than after the values are all held.
+type arrayBuilder struct {
+	budget *Budget
+	values []Object
+}
+
+func newArrayBuilder(ctx context.Context) *arrayBuilder {
+	return &arrayBuilder{budget: BudgetFromContext(ctx), values: []Object{}}
+}
+
+// add appends value, returning an error if the array no longer fits.
+func (ab *arrayBuilder) add(value Object) Object {
+	if !ab.budget.fits(16 * int64(len(ab.values)+1)) {
+		return NewLimitError("MaxAllocatedBytes", ab.budget.Limits().MaxAllocatedBytes)
+	}
+	ab.values = append(ab.values, value)
+	return nil
+}
+
+// addAllocated charges value, a new array, to the budget and appends it.
+func (ab *arrayBuilder) addAllocated(value *Array) Object {
+	if err := ab.budget.Allocate(value); err != nil {
+		return err
+	}
+	return ab.add(value)
+}
+
+// iterableValues returns the values of an array or other iterable, for builtins that need
+// them all at once.
+func iterableValues(ctx context.Context, name string, obj Object) ([]Object, Object) {
+	if arr, ok := obj.(*Array); ok {
+		return arr.Elements, nil
+	}
+	xs, err := iterableArg(name, obj)
+	if err != nil {
+		return nil, err
+	}
+	values := newArrayBuilder(ctx)
+	if err := each(ctx, xs, values.add); err != nil {
+		return nil, err
+	}
+	return values.values, nil
+}
+
+func isError(obj Object) bool {
+	_, ok := obj.(*Error)
+	return ok
+}
+
+func isTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null:
+		return false
+	default:
+		return true
+	}
+}
+
+func nativeBool(b bool) *Boolean {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
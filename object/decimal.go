@@ -0,0 +1,299 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number, Value / 10**Scale: 12.50 has a Value of 1250 and a
+// Scale of 2.  Sums, differences and products of decimals are exact; quotients are rounded
+// to the precision of the DecimalContext.
+type Decimal struct {
+	Value *big.Int
+	Scale int
+}
+
+func (d *Decimal) Type() ObjectType { return DECIMAL_OBJ }
+
+// Inspect prints d with Scale digits after the point, so 12.50 keeps its trailing zero.
+func (d *Decimal) Inspect() string {
+	digits := new(big.Int).Abs(d.Value).String()
+	if d.Scale > 0 {
+		if len(digits) <= d.Scale {
+			digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.Scale] + "." + digits[len(digits)-d.Scale:]
+	}
+	if d.Value.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// HashKey hashes equal decimals alike, whatever their scale: 1.5 and 1.50 are the same key.
+// Like a float, a decimal with an integer value hashes as that integer.
+func (d *Decimal) HashKey() HashKey {
+	n := d.normalize()
+	if n.Scale == 0 {
+		return NewInteger(n.Value).(Hashable).HashKey()
+	}
+	h := fnv.New64a()
+	if n.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(n.Value.Bytes())
+	fmt.Fprintf(h, "e%d", n.Scale)
+	return HashKey{Type: d.Type(), Value: h.Sum64()}
+}
+
+// normalize returns d without trailing zeros after the point.
+func (d *Decimal) normalize() *Decimal {
+	return d.trim(0)
+}
+
+// trim removes trailing zeros after the point, keeping at least scale digits.
+func (d *Decimal) trim(scale int) *Decimal {
+	if d.Value.Sign() == 0 {
+		return &Decimal{Value: new(big.Int), Scale: min(d.Scale, max(scale, 0))}
+	}
+	value, s := new(big.Int).Set(d.Value), d.Scale
+	ten := big.NewInt(10)
+	q, r := new(big.Int), new(big.Int)
+	for s > scale {
+		q.QuoRem(value, ten, r)
+		if r.Sign() != 0 {
+			break
+		}
+		value.Set(q)
+		s--
+	}
+	return &Decimal{Value: value, Scale: s}
+}
+
+// Rescale returns d with scale digits after the point, rounding with mode if digits are
+// dropped.
+func (d *Decimal) Rescale(scale int, mode RoundingMode) *Decimal {
+	if scale >= d.Scale {
+		value := new(big.Int).Mul(d.Value, pow10(scale-d.Scale))
+		return &Decimal{Value: value, Scale: scale}
+	}
+	divisor := pow10(d.Scale - scale)
+	q, r := new(big.Int).QuoRem(d.Value, divisor, new(big.Int))
+	return &Decimal{Value: roundQuotient(q, r, divisor, mode), Scale: scale}
+}
+
+// RoundingMode is how a Decimal is rounded when digits are dropped.
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota // to the nearest, and ties to an even digit
+	RoundHalfUp                       // to the nearest, and ties away from zero
+	RoundHalfDown                     // to the nearest, and ties toward zero
+	RoundUp                           // away from zero
+	RoundDown                         // toward zero
+	RoundCeiling                      // toward positive infinity
+	RoundFloor                        // toward negative infinity
+)
+
+// roundingModes are the names of the rounding modes in scripts.
+var roundingModes = map[string]RoundingMode{
+	"half_even": RoundHalfEven,
+	"half_up":   RoundHalfUp,
+	"half_down": RoundHalfDown,
+	"up":        RoundUp,
+	"down":      RoundDown,
+	"ceiling":   RoundCeiling,
+	"floor":     RoundFloor,
+}
+
+// roundQuotient rounds the truncated quotient q, with remainder r, of a division by divisor.
+func roundQuotient(q, r, divisor *big.Int, mode RoundingMode) *big.Int {
+	sign := r.Sign()
+	if sign == 0 {
+		return q
+	}
+	half := new(big.Int).Abs(r)
+	half.Lsh(half, 1)
+	c := half.CmpAbs(divisor)
+
+	var away bool
+	switch mode {
+	case RoundHalfEven:
+		away = c > 0 || (c == 0 && new(big.Int).Abs(q).Bit(0) == 1)
+	case RoundHalfUp:
+		away = c >= 0
+	case RoundHalfDown:
+		away = c > 0
+	case RoundUp:
+		away = true
+	case RoundDown:
+		away = false
+	case RoundCeiling:
+		away = sign > 0
+	case RoundFloor:
+		away = sign < 0
+	}
+	if away {
+		return q.Add(q, big.NewInt(int64(sign)))
+	}
+	return q
+}
+
+// DecimalContext controls the rounding of Decimal quotients.
+type DecimalContext struct {
+	Precision int // significant digits of a quotient; zero means 28
+	Rounding  RoundingMode
+}
+
+const defaultDecimalPrecision = 28
+
+func (dc DecimalContext) precision() int {
+	if dc.Precision <= 0 {
+		return defaultDecimalPrecision
+	}
+	return dc.Precision
+}
+
+type decimalContextKey struct{}
+
+// WithDecimalContext returns a context whose programs round decimals with dc.
+func WithDecimalContext(ctx context.Context, dc DecimalContext) context.Context {
+	return context.WithValue(ctx, decimalContextKey{}, dc)
+}
+
+// DecimalContextFromContext returns the DecimalContext of the running program.
+func DecimalContextFromContext(ctx context.Context) DecimalContext {
+	dc, _ := ctx.Value(decimalContextKey{}).(DecimalContext)
+	return dc
+}
+
+// ParseDecimal parses a decimal such as "12.50", "-3" or "1.5e3".
+func ParseDecimal(s string) (*Decimal, error) {
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(s), "e")
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	digits := whole + fraction
+	if strings.TrimLeft(digits, "+-") == "" || strings.ContainsAny(fraction, "+-") ||
+		strings.LastIndexAny(whole, "+-") > 0 {
+		return nil, fmt.Errorf("could not parse %q as DECIMAL", s)
+	}
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return nil, fmt.Errorf("could not parse %q as DECIMAL", s)
+	}
+	scale := len(fraction)
+	if hasExponent {
+		e, err := strconv.Atoi(exponent)
+		if err != nil || e > 1<<16 || e < -(1<<16) {
+			return nil, fmt.Errorf("could not parse %q as DECIMAL", s)
+		}
+		scale -= e
+	}
+	d := &Decimal{Value: value, Scale: scale}
+	if scale < 0 {
+		d = d.Rescale(0, RoundDown)
+	}
+	return d, nil
+}
+
+// toDecimal converts an integer, BigInt or Decimal to a Decimal.
+func toDecimal(obj Object) (*Decimal, bool) {
+	switch obj := obj.(type) {
+	case *Decimal:
+		return obj, true
+	case *Integer, *BigInt:
+		return &Decimal{Value: toBig(obj), Scale: 0}, true
+	}
+	return nil, false
+}
+
+func decimalArithmetic(ctx context.Context, operator string, a, b *Decimal) (Object, error) {
+	switch operator {
+	case "+", "-":
+		scale := max(a.Scale, b.Scale)
+		x, y := a.Rescale(scale, RoundDown).Value, b.Rescale(scale, RoundDown).Value
+		if operator == "+" {
+			return &Decimal{Value: new(big.Int).Add(x, y), Scale: scale}, nil
+		}
+		return &Decimal{Value: new(big.Int).Sub(x, y), Scale: scale}, nil
+	case "*":
+		return &Decimal{Value: new(big.Int).Mul(a.Value, b.Value), Scale: a.Scale + b.Scale}, nil
+	case "/":
+		return divideDecimals(a, b, DecimalContextFromContext(ctx))
+	case "**":
+		if b.normalize().Scale > 0 {
+			return nil, fmt.Errorf("DECIMAL exponent must be an integer, got %s", b.Inspect())
+		}
+		n := b.Rescale(0, RoundDown).Value
+		trivial := a.Scale == 0 && a.Value.CmpAbs(big.NewInt(1)) <= 0
+		cost := int64(max(a.Value.BitLen(), a.Scale, 1))
+		if !trivial && (!n.IsInt64() || absInt(n.Int64()) > maxBigIntBits/cost) {
+			return nil, fmt.Errorf("result of %s ** %s is too large", a.Inspect(), b.Inspect())
+		}
+		e := absInt(n.Int64())
+		power := &Decimal{Value: new(big.Int).Exp(a.Value, big.NewInt(e), nil), Scale: a.Scale * int(e)}
+		if n.Sign() < 0 {
+			return divideDecimals(&Decimal{Value: big.NewInt(1)}, power, DecimalContextFromContext(ctx))
+		}
+		return power, nil
+	}
+	return nil, fmt.Errorf("unknown operator: %s %s %s", DECIMAL_OBJ, operator, DECIMAL_OBJ)
+}
+
+// divideDecimals returns a / b rounded to the precision of dc.  An exact quotient keeps no
+// more digits after the point than it needs, nor fewer than a has more than b.
+func divideDecimals(a, b *Decimal, dc DecimalContext) (*Decimal, error) {
+	if b.Value.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	ideal := max(a.Scale-b.Scale, 0)
+	if a.Value.Sign() == 0 {
+		return &Decimal{Value: new(big.Int), Scale: ideal}, nil
+	}
+
+	// scale a so the quotient has more digits than the precision, then drop the extra digits
+	// in a second division that rounds
+	prec := dc.precision()
+	k := max(prec-numDigits(a.Value)+numDigits(b.Value)+1, 0)
+	numerator := new(big.Int).Mul(a.Value, pow10(k))
+	scale := a.Scale - b.Scale + k
+	divisor := new(big.Int).Set(b.Value)
+	if drop := numDigits(new(big.Int).Quo(numerator, divisor)) - prec; drop > 0 {
+		divisor.Mul(divisor, pow10(drop))
+		scale -= drop
+	}
+	q, r := new(big.Int).QuoRem(numerator, divisor, new(big.Int))
+	if divisor.Sign() < 0 {
+		// keep the remainder's sign that of the quotient, for roundQuotient
+		r.Neg(r)
+	}
+	d := &Decimal{Value: roundQuotient(q, r, divisor, dc.Rounding), Scale: scale}
+	if d.Scale < 0 {
+		d = d.Rescale(0, RoundDown)
+	}
+	return d.trim(ideal), nil
+}
+
+// compareDecimals returns -1, 0 or +1 as a is less than, equal to or greater than b.
+func compareDecimals(a, b *Decimal) int {
+	scale := max(a.Scale, b.Scale)
+	return a.Rescale(scale, RoundDown).Value.Cmp(b.Rescale(scale, RoundDown).Value)
+}
+
+func numDigits(n *big.Int) int {
+	return len(new(big.Int).Abs(n).String())
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func absInt(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -1,33 +1,38 @@
 package object
 
 import (
+	"context"
 	"os"
 	"strings"
+	"sync"
 )
 
-var env map[HashKey]HashPair
+var env = &Hash{}
 
 func init() {
-	env = make(map[HashKey]HashPair)
 	for _, v := range os.Environ() {
 		key, value, _ := strings.Cut(v, "=")
-		env[NewStringHashKey(key)] =
-			HashPair{
-				Key:   &String{Value: key},
-				Value: &String{Value: value},
-			}
+		env.Set(&String{Value: key}, &String{Value: value})
 	}
 }
 
+// Environment is safe for concurrent use: spawned tasks share the environments their
+// functions closed over.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	mu        sync.RWMutex
+	store     map[string]Object
+	outer     *Environment
+	coroutine Coroutine
+	ctx       context.Context
+}
+
+// EnvironmentHash returns the process environment variables as a hash.
+func EnvironmentHash() *Hash {
+	return env
 }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	// add default variables to the environment
-	s["ENV"] = &Hash{Pairs: env}
 	return &Environment{store: s}
 }
 
@@ -38,7 +43,9 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	val, ok := e.store[name]
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		val, ok = e.outer.Get(name)
 	}
@@ -46,6 +53,44 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
 	e.store[name] = val
+	e.mu.Unlock()
 	return val
 }
+
+// SetContext sets the context passed to builtins called in this environment, and returns
+// the one it replaces.  Every function call sets it, so a closure called by another task
+// reports that task.
+func (e *Environment) SetContext(ctx context.Context) context.Context {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	prev := e.ctx
+	e.ctx = ctx
+	return prev
+}
+
+// Context returns the context of the innermost environment that has one.
+func (e *Environment) Context() context.Context {
+	e.mu.RLock()
+	ctx := e.ctx
+	e.mu.RUnlock()
+	if ctx != nil {
+		return ctx
+	}
+	if e.outer != nil {
+		return e.outer.Context()
+	}
+	return context.Background()
+}
+
+// SetCoroutine marks the environment as the body of a running generator.
+func (e *Environment) SetCoroutine(c Coroutine) {
+	e.coroutine = c
+}
+
+// Coroutine returns the generator whose body runs in this environment, or nil.  Only the
+// environment created for the generator call is marked, yield can't appear in nested functions.
+func (e *Environment) Coroutine() Coroutine {
+	return e.coroutine
+}
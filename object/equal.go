@@ -0,0 +1,194 @@
+package object
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Equals reports whether a and b have the same value, which == and != test in both
+// engines.  Numbers are equal if their values are, whatever their types, so 1 == 1.0,
+// except that a float equals a decimal only at an integer value; NaN equals nothing.
+// Strings, bytes, booleans, ranges and null compare by value, and arrays, tuples, hashes,
+// sets and records of the same type by their elements, however deeply nested or cyclic.
+// Other objects, such as functions, are only equal to themselves.
+func Equals(a, b Object) bool {
+	return equals(a, b, map[[2]Object]bool{})
+}
+
+// floatAndDecimal reports whether one of a and b is a float without an integer value and
+// the other a decimal.  Arithmetic doesn't mix floats and decimals, and such a pair is
+// never equal, since the float's binary fraction only approximates the decimal's, so
+// 1.5 == 1.5d is false and the two hash as different keys.  Integer values still compare
+// across the types.
+func floatAndDecimal(a, b Object) bool {
+	if _, ok := a.(*Float); ok {
+		a, b = b, a
+	}
+	f, ok := b.(*Float)
+	_, decimal := a.(*Decimal)
+	return ok && decimal && f.Value != math.Trunc(f.Value)
+}
+
+func equals(a, b Object, seen map[[2]Object]bool) bool {
+	if IsNumber(a) && IsNumber(b) {
+		if isNaN(a) || isNaN(b) || floatAndDecimal(a, b) {
+			return false
+		}
+		return compareNumbers(a, b) == 0
+	}
+
+	switch a := a.(type) {
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Bytes:
+		b, ok := b.(*Bytes)
+		return ok && bytes.Equal(a.Value, b.Value)
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Range:
+		// ranges are equal when they hold the same integers, so 1..=3 == 1..4
+		b, ok := b.(*Range)
+		if !ok {
+			return false
+		}
+		aLast, aOk := a.Last()
+		bLast, bOk := b.Last()
+		if !aOk || !bOk {
+			return aOk == bOk
+		}
+		return a.Start == b.Start && aLast == bLast
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && equalElements(a, b, a.Elements, b.Elements, seen)
+	case *Tuple:
+		b, ok := b.(*Tuple)
+		return ok && equalElements(a, b, a.Elements, b.Elements, seen)
+	case *Record:
+		b, ok := b.(*Record)
+		return ok && sameRecordType(a.RecordType, b.RecordType) && equalElements(a, b, a.Values, b.Values, seen)
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		if a == b || !enterPair(seen, a, b) {
+			return true
+		}
+		for _, pair := range a.Pairs() {
+			other, ok := b.Get(pair.Key)
+			if !ok || !equals(pair.Value, other, seen) {
+				return false
+			}
+		}
+		return true
+	case *Set:
+		b, ok := b.(*Set)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, el := range a.elements {
+			if !b.Has(el) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// equalElements reports whether the elements of a and b are equal.
+func equalElements(a, b Object, x, y []Object, seen map[[2]Object]bool) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	if a == b || !enterPair(seen, a, b) {
+		return true
+	}
+	for i := range x {
+		if !equals(x[i], y[i], seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// enterPair records that a and b are being compared, returning false if they already are,
+// in which case they're equal unless some other part of them differs.
+func enterPair(seen map[[2]Object]bool, a, b Object) bool {
+	key := [2]Object{a, b}
+	if seen[key] {
+		return false
+	}
+	seen[key] = true
+	return true
+}
+
+// sameKey reports whether a and b are the same hash key: equal, or both NaN.
+func sameKey(a, b Object) bool {
+	return Equals(a, b) || (isNaN(a) && isNaN(b))
+}
+
+func isNaN(obj Object) bool {
+	f, ok := obj.(*Float)
+	return ok && math.IsNaN(f.Value)
+}
+
+// Compare returns -1, 0 or +1 as a is less than, equal to or greater than b, which < and >
+// test in both engines.  Numbers compare by value, with NaN before every other number;
+// strings and bytes compare lexically; and arrays and tuples compare element by element, a
+// shorter one coming first if it's a prefix of the other.  Other objects can't be ordered.
+//
+// A float and a decimal compare as their float values.  So a pair that Equals keeps apart
+// because the float isn't an integer, such as 1.5 and 1.5d, compares as 0: neither is less
+// than the other, though they aren't equal.
+func Compare(a, b Object) (int, error) {
+	return compare(a, b, map[[2]Object]bool{})
+}
+
+func compare(a, b Object, seen map[[2]Object]bool) (int, error) {
+	if IsNumber(a) && IsNumber(b) {
+		return compareNumbers(a, b), nil
+	}
+
+	switch a := a.(type) {
+	case *String:
+		if b, ok := b.(*String); ok {
+			return strings.Compare(a.Value, b.Value), nil
+		}
+	case *Bytes:
+		if b, ok := b.(*Bytes); ok {
+			return bytes.Compare(a.Value, b.Value), nil
+		}
+	case *Array:
+		if b, ok := b.(*Array); ok {
+			return compareElements(a, b, a.Elements, b.Elements, seen)
+		}
+	case *Tuple:
+		if b, ok := b.(*Tuple); ok {
+			return compareElements(a, b, a.Elements, b.Elements, seen)
+		}
+	}
+	return 0, fmt.Errorf("can't compare %s and %s", a.Type(), b.Type())
+}
+
+// compareElements compares the elements of a and b lexicographically.
+func compareElements(a, b Object, x, y []Object, seen map[[2]Object]bool) (int, error) {
+	if a == b || !enterPair(seen, a, b) {
+		return 0, nil
+	}
+	for i := 0; i < len(x) && i < len(y); i++ {
+		c, err := compare(x[i], y[i], seen)
+		if err != nil || c != 0 {
+			return c, err
+		}
+	}
+	return cmp.Compare(len(x), len(y)), nil
+}
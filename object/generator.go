@@ -0,0 +1,96 @@
+package object
+
+import "context"
+
+// Coroutine is the engine specific half of a generator.  Resume runs the generator body
+// until it yields or returns; sent becomes the value of the yield expression the body is
+// suspended at.  Resume reports done once the body has returned, or failed with an *Error.
+type Coroutine interface {
+	Resume(sent Object) (value Object, done bool)
+}
+
+// MethodProvider is implemented by objects with named methods, which are called as obj.name(args).
+type MethodProvider interface {
+	Method(name string) (*Builtin, bool)
+}
+
+// Generator is returned by calling a function that contains yield.  It is single pass: its
+// Iterator resumes the generator rather than starting over.
+type Generator struct {
+	coroutine Coroutine
+	running   bool
+	done      bool
+}
+
+func NewGenerator(c Coroutine) *Generator {
+	return &Generator{coroutine: c}
+}
+
+func (g *Generator) Type() ObjectType { return GENERATOR_OBJ }
+func (g *Generator) Inspect() string  { return "generator" }
+
+// Resume continues the generator with sent as the value of its pending yield.  It returns
+// NULL once the generator is done.
+func (g *Generator) Resume(sent Object) (Object, bool) {
+	if g.done {
+		return NULL, true
+	}
+	if g.running {
+		return newError("generator already running"), true
+	}
+
+	g.running = true
+	value, done := g.coroutine.Resume(sent)
+	g.running = false
+
+	if done {
+		g.done = true
+		if value == nil || value.Type() != ERROR_OBJ {
+			value = NULL
+		}
+	}
+	return value, done
+}
+
+func (g *Generator) Done() bool { return g.done }
+
+// Next implements Iterator, resuming the generator with NULL.  A body that fails yields its
+// error rather than ending the iteration quietly.
+func (g *Generator) Next() (Object, bool) {
+	value, done := g.Resume(NULL)
+	if isError(value) {
+		return value, true
+	}
+	return value, !done
+}
+
+func (g *Generator) Iterator() Iterator { return g }
+
+func (g *Generator) Method(name string) (*Builtin, bool) {
+	switch name {
+	case "next":
+		return &Builtin{Fn: func(ctx context.Context, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			value, _ := g.Resume(NULL)
+			return value
+		}}, true
+	case "send":
+		return &Builtin{Fn: func(ctx context.Context, args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			value, _ := g.Resume(args[0])
+			return value
+		}}, true
+	case "done":
+		return &Builtin{Fn: func(ctx context.Context, args ...Object) Object {
+			if g.done {
+				return TRUE
+			}
+			return FALSE
+		}}, true
+	}
+	return nil, false
+}
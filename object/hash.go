@@ -0,0 +1,193 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Hash maps keys to values.  It keeps its pairs in the order their keys were first set, in
+// which they're iterated and printed, and indexes them by HashKey.  The zero Hash is empty.
+type Hash struct {
+	index map[HashKey][]int // positions in pairs of the keys with each HashKey
+	pairs []HashPair
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+	pairs := []string{}
+
+	for _, pair := range h.pairs {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// Set sets the value of key in h.  A key that's already set keeps its place.
+func (h *Hash) Set(key, value Object) error {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+	if i, ok := h.find(hashKey, key); ok {
+		h.pairs[i].Value = value
+		return nil
+	}
+	if h.index == nil {
+		h.index = make(map[HashKey][]int)
+	}
+	h.index[hashKey] = append(h.index[hashKey], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+	return nil
+}
+
+// Get returns the value of key in h.
+func (h *Hash) Get(key Object) (Object, bool) {
+	hashKey, ok := HashKeyOf(key)
+	if !ok {
+		return nil, false
+	}
+	i, ok := h.find(hashKey, key)
+	if !ok {
+		return nil, false
+	}
+	return h.pairs[i].Value, true
+}
+
+// find returns the position in pairs of key, whose HashKey is hashKey.
+func (h *Hash) find(hashKey HashKey, key Object) (int, bool) {
+	for _, i := range h.index[hashKey] {
+		if sameKey(h.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Len returns the number of pairs in h.
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// Pairs returns the pairs of h in order, which mustn't be modified.
+func (h *Hash) Pairs() []HashPair {
+	return h.pairs
+}
+
+// The hash builtins return new hashes, leaving their arguments unchanged.
+
+// keys returns the keys of a hash in order: keys(h).
+func keys(ctx context.Context, args ...Object) Object {
+	return hashElements(ctx, "keys", args, func(pair HashPair) Object { return pair.Key })
+}
+
+// values returns the values of a hash in order: values(h).
+func values(ctx context.Context, args ...Object) Object {
+	return hashElements(ctx, "values", args, func(pair HashPair) Object { return pair.Value })
+}
+
+// items returns [key, value] for each pair of a hash in order: items(h).
+func items(ctx context.Context, args ...Object) Object {
+	return hashElements(ctx, "items", args, func(pair HashPair) Object {
+		return &Array{Elements: []Object{pair.Key, pair.Value}}
+	})
+}
+
+func hashElements(ctx context.Context, name string, args []Object, element func(HashPair) Object) Object {
+	if err := checkArgs(name, args, 1, HASH_OBJ); err != nil {
+		return err
+	}
+	pairs := args[0].(*Hash).Pairs()
+	elements := make([]Object, len(pairs))
+	for i, pair := range pairs {
+		elements[i] = element(pair)
+	}
+	return allocated(ctx, &Array{Elements: elements})
+}
+
+// has reports whether a key is set in a hash, or a value is in a set: has(h, k).
+func has(ctx context.Context, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	if _, ok := HashKeyOf(args[1]); !ok {
+		return newError("unusable as hash key: %s", args[1].Type())
+	}
+	switch arg := args[0].(type) {
+	case *Hash:
+		_, ok := arg.Get(args[1])
+		return nativeBool(ok)
+	case *Set:
+		return nativeBool(arg.Has(args[1]))
+	default:
+		return newError("argument 1 to 'has' must be HASH or SET, got %s", args[0].Type())
+	}
+}
+
+// get returns the value of a key in a hash, or the default if it isn't set, or null if
+// there's no default: get(h, k, default?).
+func get(ctx context.Context, args ...Object) Object {
+	if len(args) != 2 && len(args) != 3 {
+		return newError("wrong number of arguments. got=%d, want=2 to 3", len(args))
+	}
+	h, ok := args[0].(*Hash)
+	if !ok {
+		return newError("argument 1 to 'get' must be HASH, got %s", args[0].Type())
+	}
+	if _, ok := HashKeyOf(args[1]); !ok {
+		return newError("unusable as hash key: %s", args[1].Type())
+	}
+	if value, ok := h.Get(args[1]); ok {
+		return value
+	}
+	if len(args) == 3 {
+		return args[2]
+	}
+	return NULL
+}
+
+// deleteFn returns a hash without a key: delete(h, k).
+func deleteFn(ctx context.Context, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	h, ok := args[0].(*Hash)
+	if !ok {
+		return newError("argument 1 to 'delete' must be HASH, got %s", args[0].Type())
+	}
+	if _, ok := HashKeyOf(args[1]); !ok {
+		return newError("unusable as hash key: %s", args[1].Type())
+	}
+	result := &Hash{}
+	for _, pair := range h.pairs {
+		if !sameKey(pair.Key, args[1]) {
+			result.Set(pair.Key, pair.Value)
+		}
+	}
+	return allocated(ctx, result)
+}
+
+// merge returns the pairs of all its arguments in one hash.  A key set by more than one
+// takes the last value, in the place it first appeared: merge(h, ...).
+func merge(ctx context.Context, args ...Object) Object {
+	if len(args) == 0 {
+		return newError("wrong number of arguments. got=0, want at least 1")
+	}
+	result := &Hash{}
+	for i, arg := range args {
+		h, ok := arg.(*Hash)
+		if !ok {
+			return newError("argument %d to 'merge' must be HASH, got %s", i+1, arg.Type())
+		}
+		for _, pair := range h.pairs {
+			result.Set(pair.Key, pair.Value)
+		}
+	}
+	return allocated(ctx, result)
+}
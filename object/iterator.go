@@ -0,0 +1,114 @@
+package object
+
+// Iterator streams the values of a sequence one at a time.  Next returns false once the
+// sequence is exhausted, and an *Error value if producing the next value failed.
+type Iterator interface {
+	Next() (Object, bool)
+}
+
+// Iterable is implemented by objects whose values can be streamed without first being
+// copied into an Array.  Each call to Iterator starts from the beginning.
+type Iterable interface {
+	Iterator() Iterator
+}
+
+// Iterate calls fn with each value of the iterable until fn returns false.
+func Iterate(it Iterable, fn func(Object) bool) {
+	iter := it.Iterator()
+	for {
+		value, ok := iter.Next()
+		if !ok || !fn(value) {
+			return
+		}
+	}
+}
+
+type arrayIterator struct {
+	elements []Object
+	pos      int
+}
+
+func (ai *arrayIterator) Next() (Object, bool) {
+	if ai.pos >= len(ai.elements) {
+		return nil, false
+	}
+	ai.pos++
+	return ai.elements[ai.pos-1], true
+}
+
+// Iterator yields the elements of the array.
+func (ao *Array) Iterator() Iterator {
+	return &arrayIterator{elements: ao.Elements}
+}
+
+type stringIterator struct {
+	runes []rune
+	pos   int
+}
+
+func (si *stringIterator) Next() (Object, bool) {
+	if si.pos >= len(si.runes) {
+		return nil, false
+	}
+	si.pos++
+	return &String{Value: string(si.runes[si.pos-1])}, true
+}
+
+// Iterator yields each rune of the string as a single character String.
+func (s *String) Iterator() Iterator {
+	return &stringIterator{runes: []rune(s.Value)}
+}
+
+// Iterator yields each byte as an integer.
+func (b *Bytes) Iterator() Iterator {
+	elements := make([]Object, len(b.Value))
+	for i, c := range b.Value {
+		elements[i] = &Integer{Value: int64(c)}
+	}
+	return &arrayIterator{elements: elements}
+}
+
+// Iterator yields the keys of the hash in order.
+func (h *Hash) Iterator() Iterator {
+	keys := make([]Object, len(h.pairs))
+	for i, pair := range h.pairs {
+		keys[i] = pair.Key
+	}
+	return &arrayIterator{elements: keys}
+}
+
+// Iterator yields the elements of the tuple.
+func (t *Tuple) Iterator() Iterator {
+	return &arrayIterator{elements: t.Elements}
+}
+
+// Iterator yields the elements of the set in order.
+func (s *Set) Iterator() Iterator {
+	return &arrayIterator{elements: s.elements}
+}
+
+type rangeIterator struct {
+	next int64
+	last int64
+	done bool
+}
+
+func (ri *rangeIterator) Next() (Object, bool) {
+	if ri.done {
+		return nil, false
+	}
+	value := ri.next
+	if value == ri.last {
+		// stopping here rather than past last lets ranges end at math.MaxInt64
+		ri.done = true
+	} else {
+		ri.next++
+	}
+	return &Integer{Value: value}, true
+}
+
+// Iterator yields the integers of the range in ascending order.
+func (r *Range) Iterator() Iterator {
+	last, ok := r.Last()
+	return &rangeIterator{next: r.Start, last: last, done: !ok}
+}
@@ -0,0 +1,317 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonParse decodes a JSON document: json_parse(s).  Objects become hashes that keep the
+// order of their keys, arrays become arrays, and numbers integers if they're written without
+// a fraction or exponent and floats otherwise.
+func jsonParse(ctx context.Context, args ...Object) Object {
+	if err := checkArgs("json_parse", args, 1, STRING_OBJ); err != nil {
+		return err
+	}
+	src := args[0].(*String).Value
+	dec := json.NewDecoder(strings.NewReader(src))
+	dec.UseNumber()
+
+	value, err := decodeJSON(ctx, src, dec)
+	if err == nil {
+		if _, err = dec.Token(); err == io.EOF {
+			return value
+		}
+		if err == nil {
+			return jsonSyntaxError(src, dec.InputOffset()-1, "unexpected data after the JSON value")
+		}
+	}
+
+	var errObj *Error
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &errObj):
+		return errObj
+	case errors.As(err, &syntaxErr) && syntaxErr.Error() != "unexpected end of JSON input":
+		// the offset is just after the offending character
+		return jsonSyntaxError(src, syntaxErr.Offset-1, syntaxErr.Error())
+	case syntaxErr != nil || err == io.EOF || err == io.ErrUnexpectedEOF:
+		return jsonSyntaxError(src, int64(len(src)), "unexpected end of JSON input")
+	default:
+		return newError("'json_parse' failed: %s", err)
+	}
+}
+
+// jsonSyntaxError reports a mistake at the byte offset in src by its line and column, both
+// counted from 1.
+func jsonSyntaxError(src string, offset int64, msg string) *Error {
+	offset = max(0, min(offset, int64(len(src))))
+	before := src[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	return newError("invalid JSON at line %d, column %d: %s", line, column, msg)
+}
+
+// decodeJSON decodes the next value from dec, which reads src.
+func decodeJSON(ctx context.Context, src string, dec *json.Decoder) (Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return NULL, nil
+	case bool:
+		return nativeBool(tok), nil
+	case string:
+		return checkAllocated(ctx, &String{Value: tok})
+	case json.Number:
+		// the decoder has just read the number
+		return jsonNumber(src, dec.InputOffset()-int64(len(tok)), tok)
+	case json.Delim:
+		if tok == '[' {
+			array := &Array{Elements: []Object{}}
+			for dec.More() {
+				el, err := decodeJSON(ctx, src, dec)
+				if err != nil {
+					return nil, err
+				}
+				array.Elements = append(array.Elements, el)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return checkAllocated(ctx, array)
+		}
+
+		hash := &Hash{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeJSON(ctx, src, dec)
+			if err != nil {
+				return nil, err
+			}
+			if err := hash.Set(&String{Value: key.(string)}, value); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return checkAllocated(ctx, hash)
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
+
+// jsonNumber converts a JSON number to an integer, promoted to a BigInt if it overflows, or to
+// a float if it has a fraction or an exponent.  offset is where n starts in src.
+func jsonNumber(src string, offset int64, n json.Number) (Object, error) {
+	if !strings.ContainsAny(string(n), ".eE") {
+		if i, ok := new(big.Int).SetString(string(n), 10); ok {
+			return NewInteger(i), nil
+		}
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return nil, jsonSyntaxError(src, offset, fmt.Sprintf("number %s out of range", n))
+	}
+	return &Float{Value: f}, nil
+}
+
+// checkAllocated charges obj to the budget, returning the *Error if it doesn't fit.
+func checkAllocated(ctx context.Context, obj Object) (Object, error) {
+	if err := BudgetFromContext(ctx).Allocate(obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// jsonStringify encodes a value as JSON: json_stringify(value, indent?).  The indent is a
+// number of spaces or a string to indent each level with; without one the output is compact.
+// Hashes with string keys and records become objects; arrays, tuples and sets become arrays.
+func jsonStringify(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=1 to 2", len(args))
+	}
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *Integer:
+			if arg.Value < 0 || arg.Value > 10 {
+				return newError("indent passed to 'json_stringify' must be from 0 to 10, got %d", arg.Value)
+			}
+			indent = strings.Repeat(" ", int(arg.Value))
+		case *String:
+			indent = arg.Value
+		case *Null:
+		default:
+			return newError("argument 2 to 'json_stringify' must be INTEGER or STRING, got %s", arg.Type())
+		}
+	}
+
+	e := &jsonEncoder{indent: indent, seen: map[Object]bool{}}
+	if err := e.encode(args[0], "$", 0); err != nil {
+		return err
+	}
+	return allocated(ctx, &String{Value: e.out.String()})
+}
+
+type jsonEncoder struct {
+	out    bytes.Buffer
+	indent string
+	seen   map[Object]bool // the containers being encoded, to detect cycles
+}
+
+func (e *jsonEncoder) encode(obj Object, path string, depth int) *Error {
+	switch obj := obj.(type) {
+	case *Null:
+		e.out.WriteString("null")
+	case *Boolean:
+		e.out.WriteString(strconv.FormatBool(obj.Value))
+	case *Integer, *BigInt, *Decimal:
+		e.out.WriteString(obj.Inspect())
+	case *Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return newError("can't encode %s as JSON at %s", obj.Inspect(), path)
+		}
+		e.out.WriteString(obj.Inspect())
+	case *String:
+		writeJSONString(&e.out, obj.Value)
+	case *Array:
+		return e.encodeArray(obj, obj.Elements, path, depth)
+	case *Tuple:
+		return e.encodeArray(obj, obj.Elements, path, depth)
+	case *Set:
+		return e.encodeArray(obj, obj.Elements(), path, depth)
+	case *Hash:
+		pairs := obj.Pairs()
+		keys := make([]string, len(pairs))
+		values := make([]Object, len(pairs))
+		for i, pair := range pairs {
+			key, ok := pair.Key.(*String)
+			if !ok {
+				return newError("can't encode hash key %s as JSON at %s, keys must be STRING", pair.Key.Inspect(), path)
+			}
+			keys[i] = key.Value
+			values[i] = pair.Value
+		}
+		return e.encodeObject(obj, keys, values, path, depth)
+	case *Record:
+		return e.encodeObject(obj, obj.RecordType.Fields, obj.Values, path, depth)
+	case *Function, *CompiledFunction, *Closure:
+		// the same in both engines, though the VM's functions are closures
+		return newError("can't encode %s as JSON at %s", FUNCTION_OBJ, path)
+	default:
+		return newError("can't encode %s as JSON at %s", obj.Type(), path)
+	}
+	return nil
+}
+
+func (e *jsonEncoder) encodeArray(obj Object, elements []Object, path string, depth int) *Error {
+	if err := e.enter(obj, path); err != nil {
+		return err
+	}
+	defer delete(e.seen, obj)
+
+	e.out.WriteByte('[')
+	for i, el := range elements {
+		e.separate(i, depth+1)
+		if err := e.encode(el, fmt.Sprintf("%s[%d]", path, i), depth+1); err != nil {
+			return err
+		}
+	}
+	e.close(len(elements), depth)
+	e.out.WriteByte(']')
+	return nil
+}
+
+func (e *jsonEncoder) encodeObject(obj Object, keys []string, values []Object, path string, depth int) *Error {
+	if err := e.enter(obj, path); err != nil {
+		return err
+	}
+	defer delete(e.seen, obj)
+
+	e.out.WriteByte('{')
+	for i, key := range keys {
+		e.separate(i, depth+1)
+		writeJSONString(&e.out, key)
+		e.out.WriteByte(':')
+		if e.indent != "" {
+			e.out.WriteByte(' ')
+		}
+		if err := e.encode(values[i], fmt.Sprintf("%s[%q]", path, key), depth+1); err != nil {
+			return err
+		}
+	}
+	e.close(len(keys), depth)
+	e.out.WriteByte('}')
+	return nil
+}
+
+func (e *jsonEncoder) enter(obj Object, path string) *Error {
+	if e.seen[obj] {
+		return newError("can't encode %s that contains itself as JSON at %s", obj.Type(), path)
+	}
+	e.seen[obj] = true
+	return nil
+}
+
+// separate starts the i'th element of an array or object at depth.
+func (e *jsonEncoder) separate(i, depth int) {
+	if i > 0 {
+		e.out.WriteByte(',')
+	}
+	e.newline(depth)
+}
+
+// close ends an array or object at depth with n elements.
+func (e *jsonEncoder) close(n, depth int) {
+	if n > 0 {
+		e.newline(depth)
+	}
+}
+
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.out.WriteByte('\n')
+	for range depth {
+		e.out.WriteString(e.indent)
+	}
+}
+
+// writeJSONString writes s as a JSON string, leaving characters such as < and & unescaped.
+func writeJSONString(out *bytes.Buffer, s string) {
+	out.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\r':
+			out.WriteString(`\r`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r < 0x20 || r == '\u2028' || r == '\u2029':
+			fmt.Fprintf(out, `\u%04x`, r)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+}
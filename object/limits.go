@@ -0,0 +1,138 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// Limits caps the resources a program may use.  Zero fields are unlimited.  Instructions
+// are VM instructions, or statements for the evaluator.  MaxStackSize is the size of the VM
+// stack, which the evaluator doesn't have.
+type Limits struct {
+	MaxInstructions   int64
+	MaxCallDepth      int
+	MaxStackSize      int
+	MaxAllocations    int64 // strings, arrays and hashes created
+	MaxAllocatedBytes int64 // approximate size of the strings, arrays and hashes created
+	MaxOutputBytes    int64
+}
+
+// LimitExceeded is the cause of the error that stops a program exceeding one of its Limits.
+type LimitExceeded struct {
+	Limit string // the name of the Limits field
+	Max   int64
+}
+
+func (e *LimitExceeded) Error() string {
+	return fmt.Sprintf("%s of %d exceeded", e.Limit, e.Max)
+}
+
+// NewLimitError returns the error for a program exceeding the named limit.
+func NewLimitError(limit string, max int64) *Error {
+	cause := &LimitExceeded{Limit: limit, Max: max}
+	return &Error{Message: cause.Error(), Cause: cause}
+}
+
+// Budget tracks a program's use of its Limits.  It is shared by the tasks of the program.
+// The methods of a nil Budget never fail.
+type Budget struct {
+	limits         Limits
+	instructions   atomic.Int64
+	allocations    atomic.Int64
+	allocatedBytes atomic.Int64
+	output         atomic.Int64
+}
+
+func NewBudget(l Limits) *Budget {
+	return &Budget{limits: l}
+}
+
+func (b *Budget) Limits() Limits {
+	if b == nil {
+		return Limits{}
+	}
+	return b.limits
+}
+
+// Step counts one instruction.
+func (b *Budget) Step() *Error {
+	if b == nil || b.limits.MaxInstructions == 0 {
+		return nil
+	}
+	if b.instructions.Add(1) > b.limits.MaxInstructions {
+		return NewLimitError("MaxInstructions", b.limits.MaxInstructions)
+	}
+	return nil
+}
+
+// Allocate counts obj, a newly created value.
+func (b *Budget) Allocate(obj Object) *Error {
+	if b == nil {
+		return nil
+	}
+
+	var size int64
+	switch obj := obj.(type) {
+	case *String:
+		size = int64(len(obj.Value))
+	case *Bytes:
+		size = int64(len(obj.Value))
+	case *Array:
+		size = 16 * int64(len(obj.Elements))
+	case *Tuple:
+		size = 16 * int64(len(obj.Elements))
+	case *Record:
+		size = 16 * int64(len(obj.Values))
+	case *Hash:
+		size = 64 * int64(obj.Len())
+	case *Set:
+		size = 32 * int64(obj.Len())
+	}
+
+	if b.allocations.Add(1) > b.limits.MaxAllocations && b.limits.MaxAllocations != 0 {
+		return NewLimitError("MaxAllocations", b.limits.MaxAllocations)
+	}
+	if b.allocatedBytes.Add(size) > b.limits.MaxAllocatedBytes && b.limits.MaxAllocatedBytes != 0 {
+		return NewLimitError("MaxAllocatedBytes", b.limits.MaxAllocatedBytes)
+	}
+	return nil
+}
+
+// fits reports whether size more bytes can be allocated, so that large values can be
+// refused before they are built.
+func (b *Budget) fits(size int64) bool {
+	return b == nil || b.limits.MaxAllocatedBytes == 0 || b.allocatedBytes.Load()+size <= b.limits.MaxAllocatedBytes
+}
+
+// Output counts n bytes about to be written.
+func (b *Budget) Output(n int) *Error {
+	if b == nil || b.limits.MaxOutputBytes == 0 {
+		return nil
+	}
+	if b.output.Add(int64(n)) > b.limits.MaxOutputBytes {
+		return NewLimitError("MaxOutputBytes", b.limits.MaxOutputBytes)
+	}
+	return nil
+}
+
+type budgetKey struct{}
+
+// WithLimits returns a context that runs programs with a new Budget for l.
+func WithLimits(ctx context.Context, l Limits) context.Context {
+	return context.WithValue(ctx, budgetKey{}, NewBudget(l))
+}
+
+// BudgetFromContext returns the budget of the running program, or nil if it is unlimited.
+func BudgetFromContext(ctx context.Context) *Budget {
+	b, _ := ctx.Value(budgetKey{}).(*Budget)
+	return b
+}
+
+// allocated charges obj, created by a builtin, to the budget in ctx.
+func allocated(ctx context.Context, obj Object) Object {
+	if err := BudgetFromContext(ctx).Allocate(obj); err != nil {
+		return err
+	}
+	return obj
+}
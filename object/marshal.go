@@ -0,0 +1,457 @@
+package object
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	timeType   = reflect.TypeFor[time.Time]()
+	bigIntType = reflect.TypeFor[big.Int]()
+)
+
+// FromGo converts the Go value v to an object:
+//
+//   - integers and floats to INTEGER and FLOAT, and big.Ints and unsigned integers outside
+//     the range of an int64 to BIGINT
+//   - strings and booleans to STRING and BOOLEAN
+//   - slices and arrays to ARRAY, and maps to HASH
+//   - structs to HASH, keyed by field name, or by the name in a `monkey:"name"` tag.  Fields
+//     tagged `monkey:"-"` and unexported fields are left out.
+//   - pointers and interfaces to the value they point to, and nil to NULL
+//   - time.Time to a STRING in RFC 3339 format
+//   - Objects to themselves
+//
+// Other types, and values that contain themselves, are errors.
+func FromGo(v any) (Object, error) {
+	return fromGo(reflect.ValueOf(v), map[visit]bool{})
+}
+
+// ToGo converts obj to Go and stores it in the value target points to, reversing FromGo.
+// NULL converts to the zero value, and hash keys that name no field of a struct are
+// ignored.  An interface{} gets an int64, float64, string, bool, nil, []any, or a
+// map[string]any, or map[any]any if a hash has keys that aren't strings, and a BIGINT gets a
+// *big.Int; other objects are
+// stored unconverted.
+func ToGo(obj Object, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("ToGo target must be a non-nil pointer, got %T", target)
+	}
+	return toGo(obj, v.Elem(), map[visit]bool{})
+}
+
+// visit is a reference being converted, to detect values that contain themselves.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// convertError is a value FromGo or ToGo can't convert, and where it is.
+type convertError struct {
+	msg  string
+	path []string // innermost first
+}
+
+func (e *convertError) Error() string {
+	if len(e.path) == 0 {
+		return e.msg
+	}
+	var path strings.Builder
+	for i := len(e.path) - 1; i >= 0; i-- {
+		path.WriteString(e.path[i])
+	}
+	return fmt.Sprintf("%s at %s", e.msg, strings.TrimPrefix(path.String(), "."))
+}
+
+// within adds an element to the path of err, if it's a convertError.
+func within(err error, format string, a ...any) error {
+	if e, ok := err.(*convertError); ok {
+		e.path = append(e.path, fmt.Sprintf(format, a...))
+	}
+	return err
+}
+
+func enter(seen map[visit]bool, key visit) error {
+	if seen[key] {
+		return &convertError{msg: fmt.Sprintf("cycle in %s", key.typ)}
+	}
+	seen[key] = true
+	return nil
+}
+
+func fromGo(v reflect.Value, seen map[visit]bool) (Object, error) {
+	if !v.IsValid() {
+		return NULL, nil
+	}
+	t := v.Type()
+	if t == timeType {
+		return &String{Value: v.Interface().(time.Time).Format(time.RFC3339Nano)}, nil
+	}
+	if t == bigIntType {
+		n := v.Interface().(big.Int)
+		return NewInteger(new(big.Int).Set(&n)), nil
+	}
+	if t.Implements(objectType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return NULL, nil
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		if v.Kind() == reflect.Pointer {
+			key := visit{v.Pointer(), t}
+			if err := enter(seen, key); err != nil {
+				return nil, err
+			}
+			defer delete(seen, key)
+		}
+		return fromGo(v.Elem(), seen)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NewInteger(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Bool:
+		if v.Bool() {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			key := visit{v.Pointer(), t}
+			if err := enter(seen, key); err != nil {
+				return nil, err
+			}
+			defer delete(seen, key)
+		}
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			el, err := fromGo(v.Index(i), seen)
+			if err != nil {
+				return nil, within(err, "[%d]", i)
+			}
+			elements[i] = el
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.Len() > 0 {
+			key := visit{v.Pointer(), t}
+			if err := enter(seen, key); err != nil {
+				return nil, err
+			}
+			defer delete(seen, key)
+		}
+		// sort the keys, so the hash is the same whatever order the map iterates in
+		pairs := make([]HashPair, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := fromGo(iter.Key(), seen)
+			if err != nil {
+				return nil, within(err, "[%v]", iter.Key())
+			}
+			if _, ok := HashKeyOf(key); !ok {
+				return nil, &convertError{msg: fmt.Sprintf("unusable as hash key: %s", key.Type())}
+			}
+			value, err := fromGo(iter.Value(), seen)
+			if err != nil {
+				return nil, within(err, "[%v]", iter.Key())
+			}
+			pairs = append(pairs, HashPair{Key: key, Value: value})
+		}
+		slices.SortFunc(pairs, func(a, b HashPair) int {
+			if c, err := Compare(a.Key, b.Key); err == nil {
+				return c
+			}
+			return strings.Compare(a.Key.Inspect(), b.Key.Inspect())
+		})
+		h := &Hash{}
+		for _, pair := range pairs {
+			h.Set(pair.Key, pair.Value)
+		}
+		return h, nil
+	case reflect.Struct:
+		h := &Hash{}
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			value, err := fromGo(v.Field(i), seen)
+			if err != nil {
+				return nil, within(err, ".%s", t.Field(i).Name)
+			}
+			h.Set(&String{Value: name}, value)
+		}
+		return h, nil
+	}
+	return nil, &convertError{msg: fmt.Sprintf("unsupported type %s", t)}
+}
+
+// fieldName returns the hash key for a struct field, and false if it's left out.
+func fieldName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("monkey"), ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	}
+	return name, true
+}
+
+func toGo(obj Object, v reflect.Value, seen map[visit]bool) error {
+	t := v.Type()
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		x, err := plainGo(obj, seen)
+		if err != nil {
+			return err
+		}
+		if x == nil {
+			v.SetZero()
+		} else {
+			v.Set(reflect.ValueOf(x))
+		}
+		return nil
+	}
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+	if _, ok := obj.(*Null); ok {
+		v.SetZero()
+		return nil
+	}
+	if t == timeType {
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		tm, err := time.Parse(time.RFC3339Nano, s.Value)
+		if err != nil {
+			return &convertError{msg: fmt.Sprintf("must be an RFC 3339 time, got %q", s.Value)}
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+	}
+	if t == bigIntType {
+		if !isInteger(obj) {
+			return mismatch(obj, t)
+		}
+		v.Set(reflect.ValueOf(*new(big.Int).Set(toBig(obj))))
+		return nil
+	}
+	if t.Kind() == reflect.Pointer {
+		p := reflect.New(t.Elem())
+		if err := toGo(obj, p.Elem(), seen); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	switch obj := obj.(type) {
+	case *Integer:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(obj.Value) {
+				return &convertError{msg: fmt.Sprintf("overflows %s, got %d", t, obj.Value)}
+			}
+			v.SetInt(obj.Value)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if obj.Value < 0 || v.OverflowUint(uint64(obj.Value)) {
+				return &convertError{msg: fmt.Sprintf("overflows %s, got %d", t, obj.Value)}
+			}
+			v.SetUint(uint64(obj.Value))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(float64(obj.Value))
+			return nil
+		}
+	case *BigInt:
+		switch t.Kind() {
+		case reflect.Uint64, reflect.Uint, reflect.Uintptr:
+			if obj.Value.IsUint64() && !v.OverflowUint(obj.Value.Uint64()) {
+				v.SetUint(obj.Value.Uint64())
+				return nil
+			}
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(bigToFloat(obj.Value))
+			return nil
+		}
+		if t.Kind() >= reflect.Int && t.Kind() <= reflect.Uintptr {
+			return &convertError{msg: fmt.Sprintf("overflows %s, got %s", t, obj.Value)}
+		}
+	case *Float:
+		if t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64 {
+			v.SetFloat(obj.Value)
+			return nil
+		}
+	case *String:
+		if t.Kind() == reflect.String {
+			v.SetString(obj.Value)
+			return nil
+		}
+	case *Boolean:
+		if t.Kind() == reflect.Bool {
+			v.SetBool(obj.Value)
+			return nil
+		}
+	case *Array:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			break
+		}
+		key := visit{reflect.ValueOf(obj).Pointer(), reflect.TypeOf(obj)}
+		if err := enter(seen, key); err != nil {
+			return err
+		}
+		defer delete(seen, key)
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, len(obj.Elements), len(obj.Elements)))
+		} else if len(obj.Elements) != t.Len() {
+			return &convertError{msg: fmt.Sprintf("must have %d elements, got %d", t.Len(), len(obj.Elements))}
+		}
+		for i, el := range obj.Elements {
+			if err := toGo(el, v.Index(i), seen); err != nil {
+				return within(err, "[%d]", i)
+			}
+		}
+		return nil
+	case *Hash:
+		if t.Kind() != reflect.Map && t.Kind() != reflect.Struct {
+			break
+		}
+		key := visit{reflect.ValueOf(obj).Pointer(), reflect.TypeOf(obj)}
+		if err := enter(seen, key); err != nil {
+			return err
+		}
+		defer delete(seen, key)
+		if t.Kind() == reflect.Map {
+			return hashToMap(obj, v, seen)
+		}
+		return hashToStruct(obj, v, seen)
+	}
+	return mismatch(obj, t)
+}
+
+func mismatch(obj Object, t reflect.Type) error {
+	return &convertError{msg: fmt.Sprintf("must be %s, got %s", t, obj.Type())}
+}
+
+func hashToMap(h *Hash, v reflect.Value, seen map[visit]bool) error {
+	t := v.Type()
+	m := reflect.MakeMapWithSize(t, h.Len())
+	for _, pair := range h.Pairs() {
+		key := reflect.New(t.Key()).Elem()
+		if err := toGo(pair.Key, key, seen); err != nil {
+			return within(err, "[%s]", pair.Key.Inspect())
+		}
+		value := reflect.New(t.Elem()).Elem()
+		if err := toGo(pair.Value, value, seen); err != nil {
+			return within(err, "[%s]", pair.Key.Inspect())
+		}
+		m.SetMapIndex(key, value)
+	}
+	v.Set(m)
+	return nil
+}
+
+func hashToStruct(h *Hash, v reflect.Value, seen map[visit]bool) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := fieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+		value, ok := h.Get(&String{Value: name})
+		if !ok {
+			continue
+		}
+		if err := toGo(value, v.Field(i), seen); err != nil {
+			return within(err, ".%s", t.Field(i).Name)
+		}
+	}
+	return nil
+}
+
+// plainGo converts obj to the Go value ToGo stores in an interface{}.
+func plainGo(obj Object, seen map[visit]bool) (any, error) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, nil
+	case *BigInt:
+		return new(big.Int).Set(obj.Value), nil
+	case *Float:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *Null:
+		return nil, nil
+	case *Array:
+		var s []any
+		err := toGo(obj, reflect.ValueOf(&s).Elem(), seen)
+		return s, err
+	case *Hash:
+		for _, pair := range obj.Pairs() {
+			if _, ok := pair.Key.(*String); !ok {
+				var m map[any]any
+				err := toGo(obj, reflect.ValueOf(&m).Elem(), seen)
+				return m, err
+			}
+		}
+		var m map[string]any
+		err := toGo(obj, reflect.ValueOf(&m).Elem(), seen)
+		return m, err
+	}
+	return obj, nil
+}
+
+// convertible reports whether values of t convert to and from objects.
+func convertible(t reflect.Type) bool {
+	return supported(t, map[reflect.Type]bool{})
+}
+
+func supported(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t == timeType || t == bigIntType || t.Implements(objectType) || seen[t] {
+		return true
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		return objectType.Implements(t)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool:
+		return true
+	case reflect.Slice, reflect.Array, reflect.Pointer:
+		return supported(t.Elem(), seen)
+	case reflect.Map:
+		return supported(t.Key(), seen) && supported(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if _, ok := fieldName(t.Field(i)); ok && !supported(t.Field(i).Type, seen) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
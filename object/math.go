@@ -0,0 +1,312 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// Constants are builtins with a Value, which identifiers naming them evaluate to.
+var (
+	piConstant = &Builtin{Value: &Float{Value: math.Pi}}
+	eConstant  = &Builtin{Value: &Float{Value: math.E}}
+)
+
+// intFn converts a float, truncating it, or a decimal string to an integer: int(x).
+func intFn(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *Integer, *BigInt:
+		return arg
+	case *Decimal:
+		return NewInteger(arg.Rescale(0, RoundDown).Value)
+	case *Float:
+		return floatToInteger("int", arg.Value)
+	case *String:
+		return parseInt(arg.Value, 10)
+	default:
+		return newError("argument to 'int' not supported, got %s", args[0].Type())
+	}
+}
+
+// floatFn converts an integer or a string to a float: float(x).
+func floatFn(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *Integer, *BigInt, *Decimal:
+		f, _ := toFloat(arg)
+		return &Float{Value: f}
+	case *Float:
+		return arg
+	case *String:
+		return parseFloat(arg.Value)
+	default:
+		return newError("argument to 'float' not supported, got %s", args[0].Type())
+	}
+}
+
+// decimal converts a number or a string such as "12.50" to a decimal.  A float converts to
+// the shortest decimal that parses back to it, so decimal(0.1) is 0.1: decimal(x).
+func decimal(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *Integer, *BigInt, *Decimal:
+		d, _ := toDecimal(arg)
+		return d
+	case *Float:
+		if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+			return newError("decimal(%g) is not a finite number", arg.Value)
+		}
+		d, _ := ParseDecimal(strconv.FormatFloat(arg.Value, 'g', -1, 64))
+		return d
+	case *String:
+		d, err := ParseDecimal(arg.Value)
+		if err != nil {
+			return newError("%s", err)
+		}
+		return d
+	default:
+		return newError("argument to 'decimal' not supported, got %s", args[0].Type())
+	}
+}
+
+// str returns a string as it is, and anything else as it's printed: str(x).
+func str(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *String:
+		return arg
+	case *Bytes:
+		return bytesToString(ctx, arg)
+	}
+	return allocated(ctx, &String{Value: args[0].Inspect()})
+}
+
+// parseIntFn parses an integer in base 2 to 36, or base 10 if there's no base.  Base 0
+// takes the base from a prefix such as 0x: parse_int(s, base?).
+func parseIntFn(ctx context.Context, args ...Object) Object {
+	if err := checkArgs("parse_int", args, 1, STRING_OBJ, INTEGER_OBJ); err != nil {
+		return err
+	}
+	base := int64(10)
+	if len(args) == 2 {
+		base = args[1].(*Integer).Value
+		if base != 0 && (base < 2 || base > 36) {
+			return newError("invalid base passed to 'parse_int': %d", base)
+		}
+	}
+	return parseInt(args[0].(*String).Value, int(base))
+}
+
+func parseFloatFn(ctx context.Context, args ...Object) Object {
+	if err := checkArgs("parse_float", args, 1, STRING_OBJ); err != nil {
+		return err
+	}
+	return parseFloat(args[0].(*String).Value)
+}
+
+func parseInt(s string, base int) Object {
+	n, err := strconv.ParseInt(s, base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if n, ok := new(big.Int).SetString(s, base); ok {
+			return &BigInt{Value: n}
+		}
+	}
+	if err != nil {
+		return newError("could not parse %q as INTEGER", s)
+	}
+	return &Integer{Value: n}
+}
+
+func parseFloat(s string) Object {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return newError("could not parse %q as FLOAT", s)
+	}
+	return &Float{Value: f}
+}
+
+func abs(ctx context.Context, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	switch arg := args[0].(type) {
+	case *Integer:
+		if arg.Value < 0 {
+			return Negate(arg)
+		}
+		return arg
+	case *BigInt:
+		return NewInteger(new(big.Int).Abs(arg.Value))
+	case *Decimal:
+		return &Decimal{Value: new(big.Int).Abs(arg.Value), Scale: arg.Scale}
+	case *Float:
+		return &Float{Value: math.Abs(arg.Value)}
+	default:
+		return newError("argument to 'abs' must be a number, got %s", args[0].Type())
+	}
+}
+
+// minFn returns the smallest of its arguments, or of the elements of one array: min(xs...).
+func minFn(ctx context.Context, args ...Object) Object {
+	return extreme("min", -1, args)
+}
+
+// maxFn returns the largest of its arguments, or of the elements of one array: max(xs...).
+func maxFn(ctx context.Context, args ...Object) Object {
+	return extreme("max", 1, args)
+}
+
+// extreme returns the number comparing as want, -1 or +1, against all the others.
+func extreme(name string, want int, args []Object) Object {
+	if len(args) == 1 {
+		if arr, ok := args[0].(*Array); ok {
+			args = arr.Elements
+		}
+	}
+	if len(args) == 0 {
+		return newError("'%s' of no values", name)
+	}
+	var best Object
+	for i, arg := range args {
+		if !IsNumber(arg) {
+			return newError("argument %d to '%s' must be a number, got %s", i+1, name, arg.Type())
+		}
+		if best == nil {
+			best = arg
+			continue
+		}
+		if compareNumbers(arg, best) == want {
+			best = arg
+		}
+	}
+	return best
+}
+
+// rounder returns a builtin rounding a number to an integer with fn, or mode for a decimal.
+func rounder(name string, fn func(float64) float64, mode RoundingMode) BuiltinFunction {
+	return func(ctx context.Context, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		return roundToInteger(name, args[0], fn, mode)
+	}
+}
+
+func roundToInteger(name string, x Object, fn func(float64) float64, mode RoundingMode) Object {
+	switch x := x.(type) {
+	case *Integer, *BigInt:
+		return x
+	case *Decimal:
+		return NewInteger(x.Rescale(0, mode).Value)
+	case *Float:
+		return floatToInteger(name, fn(x.Value))
+	default:
+		return newError("argument to '%s' must be a number, got %s", name, x.Type())
+	}
+}
+
+// roundFn rounds a number to an integer, with ties away from zero for a float and the
+// rounding mode of the program for a decimal.  Given places, it rounds a decimal to that many
+// digits after the point, with the named rounding mode if there is one:
+// round(x, places?, mode?).
+func roundFn(ctx context.Context, args ...Object) Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+	}
+	mode := DecimalContextFromContext(ctx).Rounding
+	if len(args) == 1 {
+		return roundToInteger("round", args[0], math.Round, mode)
+	}
+	if err := checkArgs("round", args, 2, DECIMAL_OBJ, INTEGER_OBJ, STRING_OBJ); err != nil {
+		return err
+	}
+	places := args[1].(*Integer).Value
+	if places < 0 || places > 1<<16 {
+		return newError("places passed to 'round' must be 0 to %d, got %d", 1<<16, places)
+	}
+	if len(args) == 3 {
+		var ok bool
+		if mode, ok = roundingModes[args[2].(*String).Value]; !ok {
+			return newError("unknown rounding mode %q", args[2].(*String).Value)
+		}
+	}
+	return args[0].(*Decimal).Rescale(int(places), mode)
+}
+
+// floatFunc returns a builtin applying fn to a number, promoted to a float.
+func floatFunc(name string, fn func(float64) float64) BuiltinFunction {
+	return func(ctx context.Context, args ...Object) Object {
+		xs, err := floatArgs(name, args, 1)
+		if err != nil {
+			return err
+		}
+		return &Float{Value: fn(xs[0])}
+	}
+}
+
+// floatArgs checks that a builtin was passed n numbers, and returns them as floats.
+func floatArgs(name string, args []Object, n int) ([]float64, Object) {
+	if len(args) != n {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+	xs := make([]float64, n)
+	for i, arg := range args {
+		x, ok := toFloat(arg)
+		if !ok {
+			return nil, newError("argument %d to '%s' must be a number, got %s", i+1, name, arg.Type())
+		}
+		xs[i] = x
+	}
+	return xs, nil
+}
+
+func atan2(ctx context.Context, args ...Object) Object {
+	xs, err := floatArgs("atan2", args, 2)
+	if err != nil {
+		return err
+	}
+	return &Float{Value: math.Atan2(xs[0], xs[1])}
+}
+
+// logFn returns the natural logarithm of x, or its logarithm in base b: log(x, b?).
+func logFn(ctx context.Context, args ...Object) Object {
+	if len(args) == 1 {
+		args = append(args, &Float{Value: math.E})
+	}
+	xs, err := floatArgs("log", args, 2)
+	if err != nil {
+		return err
+	}
+	if xs[1] == math.E {
+		return &Float{Value: math.Log(xs[0])}
+	}
+	return &Float{Value: math.Log(xs[0]) / math.Log(xs[1])}
+}
+
+// pow raises x to the power y, like x ** y: pow(x, y).
+func pow(ctx context.Context, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	for i, arg := range args {
+		if !IsNumber(arg) {
+			return newError("argument %d to 'pow' must be a number, got %s", i+1, arg.Type())
+		}
+	}
+	result, err := Arithmetic(ctx, "**", args[0], args[1])
+	if err != nil {
+		return newError("%s", err)
+	}
+	return allocated(ctx, result)
+}
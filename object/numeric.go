@@ -0,0 +1,294 @@
+package object
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// Both engines share these rules for numbers.  An operation on two integers gives an
+// integer, promoted to a BigInt if it overflows an int64.  An operation on a decimal and an
+// integer or decimal gives a decimal.  If either operand is a float, an integer operand is
+// promoted to a float and the result is a float; decimals aren't promoted to floats, which
+// would lose their exactness.  Integer division truncates toward zero, and dividing an
+// integer or decimal by zero is an error rather than infinity.
+
+// ErrDivisionByZero is returned for integer or decimal division by zero.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// maxBigIntBits limits the size of the result of **, which would otherwise let a short
+// expression exhaust memory.
+const maxBigIntBits = 1 << 24
+
+// NewInteger returns n as an Integer if it fits in an int64, and as a BigInt otherwise.
+func NewInteger(n *big.Int) Object {
+	if n.IsInt64() {
+		return &Integer{Value: n.Int64()}
+	}
+	return &BigInt{Value: n}
+}
+
+// IsNumber reports whether obj is an integer, a BigInt, a decimal or a float.
+func IsNumber(obj Object) bool {
+	switch obj.(type) {
+	case *Integer, *BigInt, *Decimal, *Float:
+		return true
+	}
+	return false
+}
+
+// isInteger reports whether obj is an integer or a BigInt.
+func isInteger(obj Object) bool {
+	switch obj.(type) {
+	case *Integer, *BigInt:
+		return true
+	}
+	return false
+}
+
+// Arithmetic applies the operator +, -, *, / or ** to two numbers.  An integer raised to a
+// negative integer power is a float.  ctx holds the DecimalContext rounding decimal quotients.
+func Arithmetic(ctx context.Context, operator string, left, right Object) (Object, error) {
+	l, lok := left.(*Integer)
+	r, rok := right.(*Integer)
+	switch {
+	case lok && rok:
+		return integerArithmetic(operator, l.Value, r.Value)
+	case isInteger(left) && isInteger(right):
+		return bigArithmetic(operator, toBig(left), toBig(right))
+	}
+	a, aok := toDecimal(left)
+	b, bok := toDecimal(right)
+	if aok && bok {
+		return decimalArithmetic(ctx, operator, a, b)
+	}
+	if _, ok := left.(*Decimal); ok && IsNumber(right) {
+		return nil, fmt.Errorf("can't mix DECIMAL and %s; convert with decimal() or float()", right.Type())
+	}
+	if _, ok := right.(*Decimal); ok && IsNumber(left) {
+		return nil, fmt.Errorf("can't mix %s and DECIMAL; convert with decimal() or float()", left.Type())
+	}
+	return floatOperation(operator, left, right)
+}
+
+func floatOperation(operator string, left, right Object) (Object, error) {
+	a, aok := toFloat(left)
+	b, bok := toFloat(right)
+	if !aok || !bok {
+		return nil, fmt.Errorf("unsupported types for %s: %s %s", operator, left.Type(), right.Type())
+	}
+	return floatArithmetic(operator, a, b)
+}
+
+func floatArithmetic(operator string, a, b float64) (Object, error) {
+	switch operator {
+	case "+":
+		return &Float{Value: a + b}, nil
+	case "-":
+		return &Float{Value: a - b}, nil
+	case "*":
+		return &Float{Value: a * b}, nil
+	case "/":
+		return &Float{Value: a / b}, nil
+	case "**":
+		return &Float{Value: math.Pow(a, b)}, nil
+	}
+	return nil, fmt.Errorf("unknown operator: %s %s %s", FLOAT_OBJ, operator, FLOAT_OBJ)
+}
+
+// integerArithmetic does arithmetic on int64s, falling back to big.Ints on overflow.
+func integerArithmetic(operator string, a, b int64) (Object, error) {
+	switch operator {
+	case "+":
+		if c := a + b; (c > a) == (b > 0) {
+			return &Integer{Value: c}, nil
+		}
+	case "-":
+		if c := a - b; (c < a) == (b > 0) {
+			return &Integer{Value: c}, nil
+		}
+	case "*":
+		if c, ok := multiply(a, b); ok {
+			return &Integer{Value: c}, nil
+		}
+	case "/":
+		if b == 0 {
+			return nil, ErrDivisionByZero
+		}
+		if a != math.MinInt64 || b != -1 {
+			return &Integer{Value: a / b}, nil
+		}
+	case "**":
+		if b < 0 {
+			return floatArithmetic(operator, float64(a), float64(b))
+		}
+		if c, ok := integerPow(a, b); ok {
+			return &Integer{Value: c}, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown operator: %s %s %s", INTEGER_OBJ, operator, INTEGER_OBJ)
+	}
+	return bigArithmetic(operator, big.NewInt(a), big.NewInt(b))
+}
+
+func bigArithmetic(operator string, a, b *big.Int) (Object, error) {
+	c := new(big.Int)
+	switch operator {
+	case "+":
+		c.Add(a, b)
+	case "-":
+		c.Sub(a, b)
+	case "*":
+		c.Mul(a, b)
+	case "/":
+		if b.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		c.Quo(a, b)
+	case "**":
+		if b.Sign() < 0 {
+			return floatArithmetic(operator, bigToFloat(a), bigToFloat(b))
+		}
+		if a.CmpAbs(big.NewInt(1)) > 0 && (!b.IsInt64() || b.Int64() > maxBigIntBits/int64(a.BitLen())) {
+			return nil, fmt.Errorf("result of %s ** %s is too large", a, b)
+		}
+		c.Exp(a, b, nil)
+	default:
+		return nil, fmt.Errorf("unknown operator: %s %s %s", BIGINT_OBJ, operator, BIGINT_OBJ)
+	}
+	return NewInteger(c), nil
+}
+
+// compareNumbers returns -1, 0 or +1 as the number a is less than, equal to or greater than
+// the number b.  A float compared with a decimal is compared as floats.
+func compareNumbers(a, b Object) int {
+	l, lok := a.(*Integer)
+	r, rok := b.(*Integer)
+	switch {
+	case lok && rok:
+		return cmp.Compare(l.Value, r.Value)
+	case isInteger(a) && isInteger(b):
+		return toBig(a).Cmp(toBig(b))
+	case !isFloat(a) && !isFloat(b):
+		x, _ := toDecimal(a)
+		y, _ := toDecimal(b)
+		return compareDecimals(x, y)
+	}
+	x, _ := toFloat(a)
+	y, _ := toFloat(b)
+	return cmp.Compare(x, y)
+}
+
+func isFloat(obj Object) bool {
+	_, ok := obj.(*Float)
+	return ok
+}
+
+// Negate returns the negation of a number.
+func Negate(n Object) Object {
+	switch n := n.(type) {
+	case *Integer:
+		if n.Value == math.MinInt64 {
+			return NewInteger(new(big.Int).Neg(big.NewInt(n.Value)))
+		}
+		return &Integer{Value: -n.Value}
+	case *BigInt:
+		return NewInteger(new(big.Int).Neg(n.Value))
+	case *Decimal:
+		return &Decimal{Value: new(big.Int).Neg(n.Value), Scale: n.Scale}
+	case *Float:
+		return &Float{Value: -n.Value}
+	}
+	return nil
+}
+
+// toFloat returns the value of a number as a float.
+func toFloat(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *BigInt:
+		return bigToFloat(obj.Value), true
+	case *Decimal:
+		f, _ := new(big.Rat).SetFrac(obj.Value, pow10(obj.Scale)).Float64()
+		return f, true
+	case *Float:
+		return obj.Value, true
+	}
+	return 0, false
+}
+
+func bigToFloat(n *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(n).Float64()
+	return f
+}
+
+// toBig returns the value of an integer or a BigInt as a big.Int, which mustn't be modified.
+func toBig(obj Object) *big.Int {
+	if n, ok := obj.(*BigInt); ok {
+		return n.Value
+	}
+	return big.NewInt(obj.(*Integer).Value)
+}
+
+// floatToInteger truncates f to an integer, for builtin name.
+func floatToInteger(name string, f float64) Object {
+	f = math.Trunc(f)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return newError("%s(%g) is not a finite number", name, f)
+	}
+	if f < math.MinInt64 || f >= math.MaxInt64 {
+		n, _ := big.NewFloat(f).Int(nil)
+		return NewInteger(n)
+	}
+	return &Integer{Value: int64(f)}
+}
+
+// integerPow raises x to the power y by squaring, reporting whether the result fits in an
+// int64.
+func integerPow(x, y int64) (int64, bool) {
+	result := int64(1)
+	for y > 0 {
+		if y&1 == 1 {
+			var ok bool
+			if result, ok = multiply(result, x); !ok {
+				return 0, false
+			}
+		}
+		y >>= 1
+		if y > 0 {
+			var ok bool
+			if x, ok = multiply(x, x); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
+
+// multiply returns a*b, reporting whether it fits in an int64.
+func multiply(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	neg := (a < 0) != (b < 0)
+	hi, lo := bits.Mul64(absUint(a), absUint(b))
+	if hi != 0 || (!neg && lo > math.MaxInt64) || (neg && lo > 1<<63) {
+		return 0, false
+	}
+	if neg {
+		return -int64(lo), true
+	}
+	return int64(lo), true
+}
+
+func absUint(n int64) uint64 {
+	if n < 0 {
+		return uint64(-n)
+	}
+	return uint64(n)
+}
@@ -2,10 +2,15 @@ package object
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/code"
+	"strconv"
 	"strings"
 )
 
@@ -25,6 +30,18 @@ const (
 	HASH_OBJ
 	COMPILED_FUNCTION_OBJ
 	CLOSURE_OBJ
+	RANGE_OBJ
+	GENERATOR_OBJ
+	TASK_OBJ
+	CHANNEL_OBJ
+	BIGINT_OBJ
+	DECIMAL_OBJ
+	SET_OBJ
+	TUPLE_OBJ
+	RECORD_TYPE_OBJ
+	RECORD_OBJ
+	BYTES_OBJ
+	COMPOSITION_OBJ
 )
 
 func (o ObjectType) String() string {
@@ -54,6 +71,32 @@ func (o ObjectType) String() string {
 		name = "HASH"
 	case COMPILED_FUNCTION_OBJ:
 		name = "COMPILED_FUNCTION"
+	case CLOSURE_OBJ:
+		name = "CLOSURE"
+	case RANGE_OBJ:
+		name = "RANGE"
+	case GENERATOR_OBJ:
+		name = "GENERATOR"
+	case TASK_OBJ:
+		name = "TASK"
+	case CHANNEL_OBJ:
+		name = "CHANNEL"
+	case BIGINT_OBJ:
+		name = "BIGINT"
+	case DECIMAL_OBJ:
+		name = "DECIMAL"
+	case SET_OBJ:
+		name = "SET"
+	case TUPLE_OBJ:
+		name = "TUPLE"
+	case RECORD_TYPE_OBJ:
+		name = "RECORD_TYPE"
+	case RECORD_OBJ:
+		name = "RECORD"
+	case BYTES_OBJ:
+		name = "BYTES"
+	case COMPOSITION_OBJ:
+		name = "COMPOSITION"
 	default:
 		name = "unknown object type"
 	}
@@ -74,25 +117,6 @@ type HashPair struct {
 	Value Object
 }
 
-type Hash struct {
-	Pairs map[HashKey]HashPair
-}
-
-func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
-	pairs := []string{}
-
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
-	}
-
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
-	return out.String()
-}
-
 type Object interface {
 	Type() ObjectType
 	Inspect() string
@@ -102,10 +126,44 @@ type Float struct {
 	Value float64
 }
 
-func (f *Float) Inspect() string  { return fmt.Sprintf("%f", f.Value) }
+// Inspect prints the shortest representation that parses back to the same float, with a
+// point or an exponent so it doesn't read as an integer: 1.0, 0.1, 1e-09, Inf, -Inf and NaN.
+// Values below 1e-4 or from 1e16 have an exponent.
+func (f *Float) Inspect() string {
+	switch {
+	case math.IsInf(f.Value, 1):
+		return "Inf"
+	case math.IsInf(f.Value, -1):
+		return "-Inf"
+	case math.IsNaN(f.Value):
+		return "NaN"
+	}
+	format := byte('f')
+	if abs := math.Abs(f.Value); abs != 0 && (abs < 1e-4 || abs >= 1e16) {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f.Value, format, -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
 func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// HashKey hashes a float with an integer value, including -0, as that integer, so 1.0 and 1
+// are the same key.  Other floats hash by their IEEE 754 bits, and every NaN hashes alike.
 func (f *Float) HashKey() HashKey {
-	return HashKey{Type: f.Type(), Value: uint64(f.Value)}
+	switch {
+	case math.IsNaN(f.Value):
+		return HashKey{Type: f.Type(), Value: math.Float64bits(math.NaN())}
+	case math.IsInf(f.Value, 0) || f.Value != math.Trunc(f.Value):
+		return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+	case f.Value >= math.MinInt64 && f.Value < math.MaxInt64:
+		return (&Integer{Value: int64(f.Value)}).HashKey()
+	}
+	n, _ := big.NewFloat(f.Value).Int(nil)
+	return (&BigInt{Value: n}).HashKey()
 }
 
 type Integer struct {
@@ -118,6 +176,24 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// BigInt is an integer outside the range of an int64.  Arithmetic on integers promotes
+// results that overflow to BigInts, and demotes BigInts that fit back to Integers, so equal
+// integers always have the same type.
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Inspect() string  { return b.Value.String() }
+func (b *BigInt) Type() ObjectType { return BIGINT_OBJ }
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	if b.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(b.Value.Bytes())
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -146,17 +222,87 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// ErrCanceled and ErrDeadline are the causes of an Error returned when the context
+// passed to vm.RunContext or evaluator.EvalContext is done.
+var (
+	ErrCanceled = errors.New("execution canceled")
+	ErrDeadline = errors.New("execution deadline exceeded")
+)
+
+// Error is a Monkey runtime error.  It is also a Go error, so hosts can test its Cause with
+// errors.Is.  Stack holds the names of the functions being called, innermost first, for
+// errors that stop execution.  Frames are added with AddFrame, which keeps the trace short.
 type Error struct {
 	Message string
+	Cause   error
+	Stack   []string
+
+	lastFrame string // the function of the last frame added
+	repeats   int    // how many frames in a row have called lastFrame
+	omitted   int    // how many entries were dropped from the middle of Stack
+}
+
+// maxStackTrace is the most entries Stack holds.  Past it, the innermost half is kept and
+// the oldest entries of the outer half are replaced by a note of how many are left out.
+const maxStackTrace = 32
+
+// AddFrame adds a call of the named function to the stack trace, outside of the frames
+// already in it.  Consecutive calls of one function, as in a recursion, share an entry
+// such as "f (×1000)".
+func (e *Error) AddFrame(name string) {
+	n := len(e.Stack)
+	if n > 0 && name == e.lastFrame {
+		e.repeats++
+		e.Stack[n-1] = fmt.Sprintf("%s (×%d)", name, e.repeats)
+		return
+	}
+	e.lastFrame, e.repeats = name, 1
+
+	if n == maxStackTrace {
+		half := maxStackTrace / 2
+		if e.omitted == 0 {
+			// the entry at half gives way to the note
+			e.omitted = 1
+		}
+		// drop the oldest entry after the note
+		copy(e.Stack[half+1:], e.Stack[half+2:])
+		e.Stack = e.Stack[:n-1]
+		e.omitted++
+		e.Stack[half] = fmt.Sprintf("... (%d more)", e.omitted)
+	}
+	e.Stack = append(e.Stack, name)
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
+func (e *Error) Error() string {
+	var out strings.Builder
+	out.WriteString(e.Message)
+	for _, name := range e.Stack {
+		out.WriteString("\n\tat ")
+		out.WriteString(name)
+	}
+	return out.String()
+}
+
+func (e *Error) Unwrap() error { return e.Cause }
+
+// NewInterruptError returns the error for execution stopped because ctx is done.
+func NewInterruptError(ctx context.Context) *Error {
+	cause := ErrCanceled
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		cause = ErrDeadline
+	}
+	return &Error{Message: cause.Error(), Cause: cause}
+}
+
 type Function struct {
-	Parameters []*ast.Identifier
-	Body       *ast.BlockStatement
-	Env        *Environment
+	Name        string
+	Parameters  []*ast.Identifier
+	Body        *ast.BlockStatement
+	Env         *Environment
+	IsGenerator bool
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -191,14 +337,40 @@ func (s *String) HashKey() HashKey {
 	return NewStringHashKey(s.Value)
 }
 
-type BuiltinFunction func(args ...Object) Object
+// BuiltinFunction is the Go implementation of a builtin.  ctx identifies the calling task.
+type BuiltinFunction func(ctx context.Context, args ...Object) Object
 type Builtin struct {
-	Fn   BuiltinFunction
-	Void bool
+	Fn       BuiltinFunction
+	Void     bool
+	Requires Capability
+	Members  map[string]*Builtin // the functions of a namespace, called as namespace.name(args)
+	Value    Object              // the value of a constant, such as PI, used in place of the builtin
+}
+
+// NewNamespace returns a builtin grouping members under name.  The namespace itself can't be
+// called.
+func NewNamespace(name string, members map[string]*Builtin) *Builtin {
+	return &Builtin{
+		Fn: func(ctx context.Context, args ...Object) Object {
+			return newError("namespace %s is not a function", name)
+		},
+		Members: members,
+	}
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
-func (b *Builtin) Inspect() string  { return "builtin function" }
+func (b *Builtin) Inspect() string {
+	if b.Members != nil {
+		return "builtin namespace"
+	}
+	return "builtin function"
+}
+
+// Method returns a member of a namespace.
+func (b *Builtin) Method(name string) (*Builtin, bool) {
+	m, ok := b.Members[name]
+	return m, ok
+}
 
 type Array struct {
 	Elements []Object
@@ -219,9 +391,11 @@ func (ao *Array) Inspect() string {
 }
 
 type CompiledFunction struct {
+	Name          string
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	IsGenerator   bool
 }
 
 func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
@@ -238,3 +412,69 @@ func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
 func (c *Closure) Inspect() string {
 	return fmt.Sprintf("Closure[%p]", c)
 }
+
+// Composition is the function f >> g, which calls Second with the result of calling First.
+type Composition struct {
+	First  Object
+	Second Object
+}
+
+// Compose returns first >> second.  Both must be callable.
+func Compose(first, second Object) (*Composition, *Error) {
+	for _, fn := range []Object{first, second} {
+		switch fn.(type) {
+		case *Function, *Closure, *Builtin, *RecordType, *Composition:
+		default:
+			return nil, newError("operands of >> must be functions, got %s >> %s", first.Type(), second.Type())
+		}
+	}
+	return &Composition{First: first, Second: second}, nil
+}
+
+func (c *Composition) Type() ObjectType { return COMPOSITION_OBJ }
+func (c *Composition) Inspect() string {
+	return c.First.Inspect() + " >> " + c.Second.Inspect()
+}
+
+// Range is the sequence of integers from Start up to End, including End when Inclusive.
+// Ranges whose End comes before Start are empty.
+type Range struct {
+	Start     int64
+	End       int64
+	Inclusive bool
+}
+
+func NewRange(start, end int64, inclusive bool) *Range {
+	return &Range{Start: start, End: end, Inclusive: inclusive}
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	if r.Inclusive {
+		return fmt.Sprintf("%d..=%d", r.Start, r.End)
+	}
+	return fmt.Sprintf("%d..%d", r.Start, r.End)
+}
+
+// Last returns the last integer in the range.  It returns false when the range is empty.
+func (r *Range) Last() (int64, bool) {
+	if r.Inclusive {
+		return r.End, r.End >= r.Start
+	}
+	return r.End - 1, r.End > r.Start
+}
+
+// Len returns the number of integers in the range.  It returns false when there are more
+// than math.MaxInt64 of them, as in math.MinInt64..0.
+func (r *Range) Len() (int64, bool) {
+	last, ok := r.Last()
+	if !ok {
+		return 0, true
+	}
+	// last >= Start, so the difference is exact as a uint64
+	diff := uint64(last) - uint64(r.Start)
+	if diff >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(diff) + 1, true
+}
@@ -1,6 +1,16 @@
 package object
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -20,3 +30,693 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestSliceBounds(t *testing.T) {
+	tests := []struct {
+		start, end Object
+		lo, hi     int
+	}{
+		{NULL, NULL, 0, 5},
+		{&Integer{Value: 1}, &Integer{Value: 3}, 1, 3},
+		{&Integer{Value: -2}, NULL, 3, 5},
+		{NULL, &Integer{Value: -1}, 0, 4},
+		{&Integer{Value: -10}, &Integer{Value: 10}, 0, 5},
+		{&Integer{Value: 4}, &Integer{Value: 2}, 4, 4},
+		{&Integer{Value: 7}, NULL, 5, 5},
+	}
+
+	for _, tt := range tests {
+		lo, hi, err := SliceBounds(tt.start, tt.end, 5)
+		if err != nil {
+			t.Fatalf("[%s:%s] unexpected error: %s", tt.start.Inspect(), tt.end.Inspect(), err.Message)
+		}
+		if lo != tt.lo || hi != tt.hi {
+			t.Errorf("[%s:%s] wrong bounds. got=%d:%d, want=%d:%d", tt.start.Inspect(), tt.end.Inspect(), lo, hi, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestIterators(t *testing.T) {
+	tests := []struct {
+		iterable Iterable
+		expected []string
+	}{
+		{&Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "two"}}}, []string{"1", "two"}},
+		{&String{Value: "héllo"}, []string{"h", "é", "l", "l", "o"}},
+		{NewRange(2, 5, false), []string{"2", "3", "4"}},
+		{NewRange(2, 5, true), []string{"2", "3", "4", "5"}},
+		{NewRange(5, 2, false), []string{}},
+		{&Array{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		actual := []string{}
+		Iterate(tt.iterable, func(value Object) bool {
+			actual = append(actual, value.Inspect())
+			return true
+		})
+		if strings.Join(actual, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("wrong values. got=%v, want=%v", actual, tt.expected)
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		r       *Range
+		inspect string
+		length  int64
+		ok      bool
+	}{
+		{NewRange(1, 3, false), "1..3", 2, true},
+		{NewRange(1, 3, true), "1..=3", 3, true},
+		{NewRange(3, 1, true), "3..=1", 0, true},
+		{NewRange(math.MinInt64, 0, false), "-9223372036854775808..0", math.MaxInt64, false},
+		{NewRange(math.MinInt64, -1, false), "-9223372036854775808..-1", math.MaxInt64, true},
+		{NewRange(math.MinInt64, math.MaxInt64, true), "-9223372036854775808..=9223372036854775807", 0, false},
+		{NewRange(1, math.MaxInt64, true), "1..=9223372036854775807", math.MaxInt64, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.Inspect(); got != tt.inspect {
+			t.Errorf("wrong Inspect. got=%q, want=%q", got, tt.inspect)
+		}
+		length, ok := tt.r.Len()
+		if ok != tt.ok || (ok && length != tt.length) {
+			t.Errorf("%s: wrong Len. got=(%d, %t), want=(%d, %t)", tt.inspect, length, ok, tt.length, tt.ok)
+		}
+	}
+
+	if !Equals(NewRange(1, 3, true), NewRange(1, 4, false)) {
+		t.Errorf("1..=3 != 1..4")
+	}
+	if !Equals(NewRange(3, 1, true), NewRange(5, 5, false)) {
+		t.Errorf("empty ranges are not equal")
+	}
+}
+
+func TestIterateStopsEarly(t *testing.T) {
+	count := 0
+	Iterate(NewRange(0, 1000, false), func(value Object) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Errorf("iteration did not stop. got=%d values", count)
+	}
+}
+
+func TestChannelCloseWakesReceivers(t *testing.T) {
+	ch := NewChannel(0)
+	results := make(chan bool)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, ok, err := ch.Recv(context.Background())
+			results <- ok || err != nil
+		}()
+	}
+
+	// receivers may not have parked yet: closing must wake them either way
+	if err := ch.Close(); err != nil {
+		t.Fatalf("close failed: %s", err.Message)
+	}
+	for i := 0; i < 2; i++ {
+		if <-results {
+			t.Errorf("receive from closed channel reported a value")
+		}
+	}
+}
+
+func TestSelectPrefersFirstReadyCase(t *testing.T) {
+	a, b := NewChannel(1), NewChannel(1)
+	a.Send(context.Background(), &Integer{Value: 1})
+	b.Send(context.Background(), &Integer{Value: 2})
+
+	index, value, err := Select(context.Background(), []SelectCase{{Channel: b}, {Channel: a}})
+	if err != nil {
+		t.Fatalf("select failed: %s", err.Message)
+	}
+	if index != 0 || value.(*Integer).Value != 2 {
+		t.Errorf("wrong case selected. got=%d (%s)", index, value.Inspect())
+	}
+}
+
+func TestRecvInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+
+	_, _, err := NewChannel(0).Recv(ctx)
+	if err == nil || !errors.Is(err, ErrCanceled) {
+		t.Errorf("wrong error from interrupted receive. got=%v", err)
+	}
+}
+
+func TestSchedulersAreIndependent(t *testing.T) {
+	// a task of another program that never blocks mustn't hide this program's deadlock
+	busy := NewScheduler().NewTask("busy")
+	busy.Start()
+	defer busy.Finish(nil)
+
+	sched := NewScheduler()
+	main := sched.NewTask("main")
+	main.Start()
+	defer main.Finish(nil)
+
+	_, _, err := sched.NewChannel(0).Recv(WithTask(context.Background(), main))
+	if err == nil || err.Message != "deadlock: all tasks are blocked: main (recv)" {
+		t.Errorf("wrong error from deadlocked receive. got=%v", err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	exec := GetBuiltinByName("exec")
+	length := GetBuiltinByName("len")
+	tests := []struct {
+		policy  *Policy
+		name    string
+		builtin *Builtin
+		allowed bool
+	}{
+		{nil, "exec", exec, true},
+		{SandboxPolicy(), "exec", exec, false},
+		{SandboxPolicy(), "len", length, true},
+		{&Policy{Capabilities: CapProcess}, "exec", exec, true},
+		{&Policy{Builtins: map[string]bool{"len": true}, Capabilities: AllCapabilities}, "exec", exec, false},
+		{&Policy{Builtins: map[string]bool{"len": true}}, "len", length, true},
+	}
+
+	for i, tt := range tests {
+		err := tt.policy.CheckBuiltin(tt.name, tt.builtin)
+		if tt.allowed && err != nil {
+			t.Errorf("tests[%d]: %s denied: %s", i, tt.name, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrDenied) {
+			t.Errorf("tests[%d]: %s not denied, got=%v", i, tt.name, err)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		fn       any
+		args     []Object
+		expected string
+	}{
+		{func(a, b int) int { return a + b }, []Object{&Integer{Value: 1}, &Integer{Value: 2}}, "3"},
+		{func(x float64) float64 { return x / 2 }, []Object{&Integer{Value: 3}}, "1.5"},
+		{func(s string, n uint8) string { return strings.Repeat(s, int(n)) }, []Object{&String{Value: "ab"}, &Integer{Value: 2}}, "abab"},
+		{func(xs ...int) []int { return xs }, []Object{&Integer{Value: 1}, &Integer{Value: 2}}, "[1, 2]"},
+		{func(m map[string]bool) int { return len(m) }, []Object{&Hash{}}, "0"},
+		{func(ctx context.Context, o Object) Object { return o }, []Object{TRUE}, "true"},
+		{func(b bool) (bool, error) { return !b, nil }, []Object{TRUE}, "false"},
+		{func() error { return errors.New("failed") }, nil, "ERROR: failed"},
+		{func() {}, nil, "null"},
+		{func() any { return 7 }, nil, "7"},
+		{func(n int8) int8 { return n }, []Object{&Integer{Value: 300}}, "ERROR: argument 1 to 'f' overflows int8, got 300"},
+		{func(s string) string { return s }, []Object{TRUE}, "ERROR: argument 1 to 'f' must be string, got BOOLEAN"},
+		{func(a, b int) int { return a }, []Object{&Integer{Value: 1}}, "ERROR: wrong number of arguments, got=1, want=2"},
+	}
+
+	for i, tt := range tests {
+		r := NewRegistry()
+		if err := r.Register("f", tt.fn); err != nil {
+			t.Fatalf("tests[%d]: %s", i, err)
+		}
+		f, _ := r.Lookup("f")
+		result := f.Fn(context.Background(), tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("tests[%d]: wrong result. want=%q, got=%q", i, tt.expected, result.Inspect())
+		}
+	}
+
+	for _, fn := range []any{42, func(chan int) {}, func() (int, int) { return 0, 0 }} {
+		if err := NewRegistry().Register("f", fn); err == nil {
+			t.Errorf("registered unsupported function %T", fn)
+		}
+	}
+}
+
+type testServer struct {
+	Host    string `monkey:"host"`
+	Port    uint16 `monkey:"port"`
+	Tags    []string
+	Started time.Time `monkey:"started"`
+	Backup  *testServer
+	Secret  string `monkey:"-"`
+	private int
+}
+
+func TestFromGoToGo(t *testing.T) {
+	started := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	in := testServer{
+		Host:    "localhost",
+		Port:    8080,
+		Tags:    []string{"a", "b"},
+		Started: started,
+		Backup:  &testServer{Host: "backup"},
+		Secret:  "hidden",
+		private: 1,
+	}
+
+	obj, err := FromGo(in)
+	if err != nil {
+		t.Fatalf("FromGo: %s", err)
+	}
+	hash := obj.(*Hash)
+	for key, expected := range map[string]string{
+		"host":    "localhost",
+		"port":    "8080",
+		"Tags":    "[a, b]",
+		"started": "2024-03-01T12:00:00Z",
+	} {
+		value, ok := hash.Get(&String{Value: key})
+		if !ok || value.Inspect() != expected {
+			t.Errorf("wrong value for %s. want=%s, got=%v", key, expected, value)
+		}
+	}
+	for _, key := range []string{"Secret", "private"} {
+		if _, ok := hash.Get(&String{Value: key}); ok {
+			t.Errorf("field %s converted", key)
+		}
+	}
+
+	var out testServer
+	if err := ToGo(obj, &out); err != nil {
+		t.Fatalf("ToGo: %s", err)
+	}
+	in.Secret, in.private = "", 0
+	if out.Host != in.Host || out.Port != in.Port || len(out.Tags) != 2 || !out.Started.Equal(started) ||
+		out.Backup == nil || out.Backup.Host != "backup" || out.Backup.Backup != nil {
+		t.Errorf("wrong round trip. want=%+v, got=%+v", in, out)
+	}
+
+	var plain any
+	if err := ToGo(obj, &plain); err != nil {
+		t.Fatalf("ToGo: %s", err)
+	}
+	if m, ok := plain.(map[string]any); !ok || m["port"] != int64(8080) || m["Backup"].(map[string]any)["Backup"] != nil {
+		t.Errorf("wrong plain value. got=%#v", plain)
+	}
+}
+
+func TestFromGoToGoErrors(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+	cyclic := &node{}
+	cyclic.Next = cyclic
+	loop := []any{nil}
+	loop[0] = loop
+
+	fromTests := []struct {
+		value    any
+		expected string
+	}{
+		{cyclic, "cycle in *object.node at Next"},
+		{loop, "cycle in []interface {} at [0]"},
+		{map[string]any{"f": func() {}}, "unsupported type func() at [f]"},
+		{struct{ C []chan int }{[]chan int{nil}}, "unsupported type chan int at C[0]"},
+	}
+	for _, tt := range fromTests {
+		_, err := FromGo(tt.value)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("FromGo(%T): wrong error. want=%q, got=%v", tt.value, tt.expected, err)
+		}
+	}
+
+	hash, _ := FromGo(map[string]any{"host": "localhost", "Backup": map[string]any{"Tags": []any{1}}})
+	var server testServer
+	err := ToGo(hash, &server)
+	if err == nil || err.Error() != "must be string, got INTEGER at Backup.Tags[0]" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+	if err := ToGo(hash, server); err == nil {
+		t.Errorf("ToGo accepted a non-pointer")
+	}
+	var ints [2]int
+	err = ToGo(&Array{Elements: []Object{&Integer{Value: 1}}}, &ints)
+	if err == nil || err.Error() != "must have 2 elements, got 1" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+	var n int64
+	err = ToGo(&BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 64)}, &n)
+	if err == nil || err.Error() != "overflows int64, got 18446744073709551616" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestFromGoToGoBigInt(t *testing.T) {
+	obj, err := FromGo(uint64(1 << 63))
+	if err != nil || obj.Type() != BIGINT_OBJ || obj.Inspect() != "9223372036854775808" {
+		t.Errorf("wrong BigInt. got=%v, %v", obj, err)
+	}
+	var u uint64
+	if err := ToGo(obj, &u); err != nil || u != 1<<63 {
+		t.Errorf("wrong uint64. got=%d, %v", u, err)
+	}
+
+	obj, err = FromGo(big.NewInt(42))
+	if err != nil || obj.Type() != INTEGER_OBJ {
+		t.Errorf("big.Int in range not demoted. got=%v, %v", obj, err)
+	}
+	var n big.Int
+	if err := ToGo(&BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 70)}, &n); err != nil || n.BitLen() != 71 {
+		t.Errorf("wrong big.Int. got=%s, %v", &n, err)
+	}
+}
+
+func TestFromGoSortsMapKeys(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		obj, err := FromGo(map[string]int{"b": 2, "c": 3, "a": 1})
+		if err != nil || obj.Inspect() != "{a: 1, b: 2, c: 3}" {
+			t.Fatalf("wrong hash. got=%v, %v", obj, err)
+		}
+	}
+}
+
+func TestDecimalRescale(t *testing.T) {
+	modes := []RoundingMode{RoundHalfEven, RoundHalfUp, RoundHalfDown, RoundUp, RoundDown, RoundCeiling, RoundFloor}
+	tests := []struct {
+		input    string
+		expected [7]string // by mode, in the order of modes
+	}{
+		{"2.5", [7]string{"2", "3", "2", "3", "2", "3", "2"}},
+		{"3.5", [7]string{"4", "4", "3", "4", "3", "4", "3"}},
+		{"-2.5", [7]string{"-2", "-3", "-2", "-3", "-2", "-2", "-3"}},
+		{"2.51", [7]string{"3", "3", "3", "3", "2", "3", "2"}},
+		{"-0.2", [7]string{"0", "0", "0", "-1", "0", "0", "-1"}},
+		{"7", [7]string{"7", "7", "7", "7", "7", "7", "7"}},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.input)
+		if err != nil {
+			t.Fatalf("ParseDecimal(%q): %s", tt.input, err)
+		}
+		for i, mode := range modes {
+			if got := d.Rescale(0, mode).Inspect(); got != tt.expected[i] {
+				t.Errorf("%s rounded with mode %d: want=%s, got=%s", tt.input, mode, tt.expected[i], got)
+			}
+		}
+	}
+
+	a, _ := ParseDecimal("1.50")
+	b, _ := ParseDecimal("1.5")
+	if a.HashKey() != b.HashKey() {
+		t.Errorf("equal decimals have different hash keys")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1, "1.0"},
+		{-2.5, "-2.5"},
+		{0.1, "0.1"},
+		{1e-9, "1e-09"},
+		{1e21, "1e+21"},
+		{123456789, "123456789.0"},
+		{math.Copysign(0, -1), "-0.0"},
+		{math.Inf(1), "Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		if got := (&Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("wrong Inspect for %v. want=%s, got=%s", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestFloatInspectRoundTrips(t *testing.T) {
+	roundTrips := func(f float64) bool {
+		parsed, err := strconv.ParseFloat((&Float{Value: f}).Inspect(), 64)
+		return err == nil && parsed == f
+	}
+	if err := quick.Check(roundTrips, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	// distinct floats have distinct keys, except for integers and NaNs
+	distinct := func(a, b float64) bool {
+		return a == b || (&Float{Value: a}).HashKey() != (&Float{Value: b}).HashKey()
+	}
+	if err := quick.Check(distinct, nil); err != nil {
+		t.Error(err)
+	}
+	// a float with an integer value hashes as the integer
+	integral := func(n int32) bool {
+		return (&Float{Value: float64(n)}).HashKey() == (&Integer{Value: int64(n)}).HashKey()
+	}
+	if err := quick.Check(integral, nil); err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		a, b Hashable
+		same bool
+	}{
+		{&Float{Value: 1.1}, &Float{Value: 1.9}, false},
+		{&Float{Value: -1.5}, &Float{Value: 1.5}, false},
+		{&Float{Value: 0}, &Float{Value: math.Copysign(0, -1)}, true},
+		{&Float{Value: math.NaN()}, &Float{Value: -math.NaN()}, true},
+		{&Float{Value: math.Inf(1)}, &Float{Value: math.Inf(-1)}, false},
+		{&Float{Value: 1e30}, &BigInt{Value: new(big.Int).SetUint64(1e15)}, false},
+		{&Float{Value: 1 << 62}, &Integer{Value: 1 << 62}, true},
+		{&Float{Value: 1 << 70}, &BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 70)}, true},
+		{&Float{Value: 2}, &Decimal{Value: big.NewInt(200), Scale: 2}, true},
+	}
+	for _, tt := range tests {
+		if same := tt.a.HashKey() == tt.b.HashKey(); same != tt.same {
+			t.Errorf("%s and %s: same hash key %t, want %t", tt.a.(Object).Inspect(), tt.b.(Object).Inspect(), same, tt.same)
+		}
+	}
+}
+
+func TestEqualNumbersHashAlike(t *testing.T) {
+	numbers := func(n int16) []Object {
+		return []Object{
+			&Integer{Value: int64(n)},
+			&Float{Value: float64(n)},
+			&Float{Value: float64(n) + 0.5},
+			&Float{Value: math.Ldexp(float64(n), 70)},
+			&Decimal{Value: big.NewInt(int64(n)), Scale: 0},
+			&Decimal{Value: big.NewInt(int64(n)*10 + 5), Scale: 1},
+			&Decimal{Value: big.NewInt(int64(n)*100 + 50), Scale: 2},
+			NewInteger(new(big.Int).Lsh(big.NewInt(int64(n)), 70)),
+		}
+	}
+	// numbers that are equal have the same hash key
+	consistent := func(m, n int16) bool {
+		for _, a := range numbers(m) {
+			for _, b := range numbers(n) {
+				ka, _ := HashKeyOf(a)
+				kb, _ := HashKeyOf(b)
+				if Equals(a, b) && ka != kb {
+					t.Logf("%s == %s but their hash keys differ", a.Inspect(), b.Inspect())
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if err := quick.Check(consistent, nil); err != nil {
+		t.Error(err)
+	}
+	if err := quick.Check(func(n int16) bool { return consistent(n, n) }, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEqualsAndCompareCyclic(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	a.Elements[1] = a
+	b := &Array{Elements: []Object{&Integer{Value: 1}, nil}}
+	b.Elements[1] = b
+	c := &Array{Elements: []Object{&Integer{Value: 2}, nil}}
+	c.Elements[1] = c
+
+	if !Equals(a, b) {
+		t.Errorf("equal cyclic arrays aren't equal")
+	}
+	if Equals(a, c) {
+		t.Errorf("different cyclic arrays are equal")
+	}
+	if n, err := Compare(a, b); err != nil || n != 0 {
+		t.Errorf("Compare(a, b) = %d, %v, want 0", n, err)
+	}
+	if n, err := Compare(a, c); err != nil || n != -1 {
+		t.Errorf("Compare(a, c) = %d, %v, want -1", n, err)
+	}
+
+	key := &String{Value: "self"}
+	h, g := &Hash{}, &Hash{}
+	h.Set(key, h)
+	g.Set(key, g)
+	if !Equals(h, g) {
+		t.Errorf("equal cyclic hashes aren't equal")
+	}
+}
+
+func TestHashKeyOf(t *testing.T) {
+	point := &RecordType{Name: "Point", Fields: []string{"x", "y"}}
+	tests := []struct {
+		a, b     Object
+		hashable bool
+		same     bool
+	}{
+		{&Tuple{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}, &Tuple{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}, true, true},
+		{&Tuple{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}, &Tuple{Elements: []Object{&Integer{Value: 2}, &Integer{Value: 1}}}, true, false},
+		{&Tuple{Elements: []Object{&Integer{Value: 1}}}, &Integer{Value: 1}, true, false},
+		{&Record{RecordType: point, Values: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}, &Tuple{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}}}, true, false},
+		{&Tuple{Elements: []Object{&Array{}}}, nil, false, false},
+		{&Record{RecordType: point, Values: []Object{&Integer{Value: 1}, &Hash{}}}, nil, false, false},
+	}
+
+	for _, tt := range tests {
+		a, ok := HashKeyOf(tt.a)
+		if ok != tt.hashable {
+			t.Errorf("HashKeyOf(%s) hashable=%t, want %t", tt.a.Inspect(), ok, tt.hashable)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if b, _ := HashKeyOf(tt.b); (a == b) != tt.same {
+			t.Errorf("HashKeyOf(%s) == HashKeyOf(%s) is %t, want %t", tt.a.Inspect(), tt.b.Inspect(), a == b, tt.same)
+		}
+	}
+}
+
+// collider is a hash key whose HashKey is the same for every value.
+type collider struct{ String }
+
+func (c *collider) HashKey() HashKey { return HashKey{Type: STRING_OBJ} }
+
+func TestHashKeyCollisions(t *testing.T) {
+	a, b, c := &collider{String{Value: "a"}}, &collider{String{Value: "b"}}, &collider{String{Value: "c"}}
+
+	h := &Hash{}
+	h.Set(a, &Integer{Value: 1})
+	h.Set(b, &Integer{Value: 2})
+	h.Set(a, &Integer{Value: 3})
+	if h.Len() != 2 {
+		t.Errorf("hash has %d pairs, want 2", h.Len())
+	}
+	for _, tt := range []struct {
+		key      Object
+		expected int64
+		ok       bool
+	}{{a, 3, true}, {b, 2, true}, {c, 0, false}} {
+		value, ok := h.Get(tt.key)
+		if ok != tt.ok {
+			t.Errorf("Get(%s) found=%t, want %t", tt.key.Inspect(), ok, tt.ok)
+		} else if ok && value.(*Integer).Value != tt.expected {
+			t.Errorf("Get(%s) = %s, want %d", tt.key.Inspect(), value.Inspect(), tt.expected)
+		}
+	}
+
+	s := &Set{}
+	s.Add(a)
+	s.Add(b)
+	s.Add(a)
+	if s.Len() != 2 || !s.Has(a) || !s.Has(b) || s.Has(c) {
+		t.Errorf("set is %s, want #{a, b}", s.Inspect())
+	}
+	// adding to a copy leaves the original's buckets alone
+	s.copy().Add(c)
+	if s.Has(c) {
+		t.Errorf("adding to a copy changed the set to %s", s.Inspect())
+	}
+}
+
+func TestErrorAddFrame(t *testing.T) {
+	err := &Error{Message: "boom"}
+	for i := 0; i < 3; i++ {
+		err.AddFrame("f")
+	}
+	err.AddFrame("<main>")
+	if expected := "boom\n\tat f (×3)\n\tat <main>"; err.Error() != expected {
+		t.Errorf("wrong trace. want=%q, got=%q", expected, err.Error())
+	}
+
+	err = &Error{Message: "boom"}
+	for i := 0; i < 100; i++ {
+		err.AddFrame("f")
+		err.AddFrame("g")
+	}
+	err.AddFrame("<main>")
+	if len(err.Stack) != maxStackTrace {
+		t.Fatalf("trace has %d entries, want %d", len(err.Stack), maxStackTrace)
+	}
+	half := maxStackTrace / 2
+	if err.Stack[0] != "f" || err.Stack[half-1] != "g" || err.Stack[half] != "... (170 more)" || err.Stack[maxStackTrace-1] != "<main>" {
+		t.Errorf("wrong trace: %v", err.Stack)
+	}
+}
+
+func TestJSONParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": [1, 2.5, 1e2, -0, null, true], "a": {"x": "é\né"}}`, "{b: [1, 2.5, 100.0, 0, null, true], a: {x: é\né}}"},
+		{`{"a": 1, "a": 2, "b": 3}`, "{a: 2, b: 3}"},
+		{`99999999999999999999`, "99999999999999999999"},
+		{` "s" `, "s"},
+		{`[]`, "[]"},
+		{"{\"a\": 1,\n  \"b\": x}", "ERROR: invalid JSON at line 2, column 8: invalid character 'x' looking for beginning of value"},
+		{`{"a" 1}`, "ERROR: invalid JSON at line 1, column 6: invalid character '1' after object key"},
+		{`[1, 2`, "ERROR: invalid JSON at line 1, column 6: unexpected end of JSON input"},
+		{`[1] 2`, "ERROR: invalid JSON at line 1, column 5: unexpected data after the JSON value"},
+		{"[1,\n  1e400]", "ERROR: invalid JSON at line 2, column 3: number 1e400 out of range"},
+		{``, "ERROR: invalid JSON at line 1, column 1: unexpected end of JSON input"},
+	}
+
+	for _, tt := range tests {
+		result := jsonParse(context.Background(), &String{Value: tt.input})
+		if result.Inspect() != tt.expected {
+			t.Errorf("json_parse(%q) wrong result. want=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestJSONStringify(t *testing.T) {
+	cyclic := &Array{}
+	cyclic.Elements = []Object{cyclic}
+	hash := &Hash{}
+	hash.Set(&String{Value: "b"}, &Array{Elements: []Object{&Integer{Value: 1}, &Float{Value: 2.5}, NULL}})
+	hash.Set(&String{Value: "a"}, &String{Value: "<\"\t\x01>"})
+	hash.Set(&String{Value: "c"}, &Hash{})
+
+	tests := []struct {
+		args     []Object
+		expected string
+	}{
+		{[]Object{hash}, `{"b":[1,2.5,null],"a":"<\"\t\u0001>","c":{}}`},
+		{[]Object{hash, &Integer{Value: 2}}, "{\n  \"b\": [\n    1,\n    2.5,\n    null\n  ],\n  \"a\": \"<\\\"\\t\\u0001>\",\n  \"c\": {}\n}"},
+		{[]Object{&Array{Elements: []Object{TRUE}}, &String{Value: "\t"}}, "[\n\ttrue\n]"},
+		{[]Object{&Tuple{Elements: []Object{&Float{Value: 1}, &Decimal{Value: big.NewInt(1250), Scale: 2}}}}, "[1.0,12.50]"},
+		{[]Object{&Record{RecordType: &RecordType{Name: "P", Fields: []string{"x"}}, Values: []Object{&Integer{Value: 1}}}}, `{"x":1}`},
+		{[]Object{&Array{Elements: []Object{&Builtin{}}}}, "ERROR: can't encode BUILTIN as JSON at $[0]"},
+		{[]Object{&Float{Value: math.NaN()}}, "ERROR: can't encode NaN as JSON at $"},
+		{[]Object{cyclic}, "ERROR: can't encode ARRAY that contains itself as JSON at $[0]"},
+		{[]Object{NULL, &Integer{Value: -1}}, "ERROR: indent passed to 'json_stringify' must be from 0 to 10, got -1"},
+	}
+
+	for _, tt := range tests {
+		result := jsonStringify(context.Background(), tt.args...)
+		if s, ok := result.(*String); ok {
+			if s.Value != tt.expected {
+				t.Errorf("wrong result. want=%s, got=%s", tt.expected, s.Value)
+			}
+		} else if result.Inspect() != tt.expected {
+			t.Errorf("wrong result. want=%s, got=%s", tt.expected, result.Inspect())
+		}
+	}
+}
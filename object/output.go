@@ -0,0 +1,36 @@
+package object
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+type output struct {
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type outputKey struct{}
+
+// WithOutput returns a context whose programs write to stdout and stderr rather than the
+// process's.  puts writes to stdout, and exec passes stderr to the commands it runs.
+func WithOutput(ctx context.Context, stdout, stderr io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, output{stdout: stdout, stderr: stderr})
+}
+
+// Stdout returns the writer for the output of the running program.
+func Stdout(ctx context.Context) io.Writer {
+	if out, ok := ctx.Value(outputKey{}).(output); ok && out.stdout != nil {
+		return out.stdout
+	}
+	return os.Stdout
+}
+
+// Stderr returns the writer for the error output of the running program.
+func Stderr(ctx context.Context) io.Writer {
+	if out, ok := ctx.Value(outputKey{}).(output); ok && out.stderr != nil {
+		return out.stderr
+	}
+	return os.Stderr
+}
@@ -0,0 +1,87 @@
+package object
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Capability is access to the world outside the interpreter that a builtin needs.
+type Capability uint
+
+const (
+	CapProcess    Capability = 1 << iota // running commands
+	CapEnv                               // reading the process environment through ENV
+	CapFilesystem                        // reading and writing files
+	CapClock                             // reading the time
+
+	AllCapabilities = CapProcess | CapEnv | CapFilesystem | CapClock
+)
+
+func (c Capability) String() string {
+	names := []string{}
+	for _, known := range []struct {
+		c    Capability
+		name string
+	}{{CapProcess, "process"}, {CapEnv, "env"}, {CapFilesystem, "filesystem"}, {CapClock, "clock"}} {
+		if c&known.c != 0 {
+			names = append(names, known.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// ErrDenied is the cause of errors for builtins and capabilities a Policy doesn't allow.
+var ErrDenied = errors.New("denied by policy")
+
+// Policy decides which builtins and capabilities a program may use.  A nil *Policy allows
+// everything.
+type Policy struct {
+	Builtins     map[string]bool // the builtins allowed, or nil for every builtin
+	Capabilities Capability
+}
+
+// SandboxPolicy allows the builtins that need no capabilities.
+func SandboxPolicy() *Policy {
+	return &Policy{}
+}
+
+func (p *Policy) Allows(c Capability) bool {
+	return p == nil || p.Capabilities&c == c
+}
+
+// CheckCapability returns an error wrapping ErrDenied if name, which needs c, isn't allowed.
+func (p *Policy) CheckCapability(name string, c Capability) error {
+	if !p.Allows(c) {
+		return fmt.Errorf("%s %w: requires the %s capability", name, ErrDenied, c&^p.Capabilities)
+	}
+	return nil
+}
+
+// CheckBuiltin returns an error wrapping ErrDenied if the builtin called name isn't allowed.
+func (p *Policy) CheckBuiltin(name string, b *Builtin) error {
+	if p == nil {
+		return nil
+	}
+	if p.Builtins != nil && !p.Builtins[name] {
+		return fmt.Errorf("%s %w: not an allowed builtin", name, ErrDenied)
+	}
+	if b == nil {
+		return nil
+	}
+	return p.CheckCapability(name, b.Requires)
+}
+
+type policyKey struct{}
+
+// WithPolicy returns a context that runs programs under p.
+func WithPolicy(ctx context.Context, p *Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+// PolicyFromContext returns the policy of the running program, or nil if it is unrestricted.
+func PolicyFromContext(ctx context.Context) *Policy {
+	p, _ := ctx.Value(policyKey{}).(*Policy)
+	return p
+}
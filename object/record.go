@@ -0,0 +1,114 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+)
+
+// Tuple is an immutable sequence, written (a, b).  Unlike an array, a tuple can be a hash key
+// if its elements can.
+type Tuple struct {
+	Elements []Object
+}
+
+func (t *Tuple) Type() ObjectType { return TUPLE_OBJ }
+
+// Inspect prints a tuple of one element with a trailing comma, (1,), as it's written.
+func (t *Tuple) Inspect() string {
+	elements := []string{}
+	for _, el := range t.Elements {
+		elements = append(elements, el.Inspect())
+	}
+	if len(elements) == 1 {
+		return "(" + elements[0] + ",)"
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+
+// RecordType is declared by record Name { fields }, and called with a value for each field,
+// in order, to construct a Record.
+type RecordType struct {
+	Name   string
+	Fields []string
+}
+
+func (rt *RecordType) Type() ObjectType { return RECORD_TYPE_OBJ }
+func (rt *RecordType) Inspect() string {
+	return fmt.Sprintf("record %s { %s }", rt.Name, strings.Join(rt.Fields, ", "))
+}
+
+// New returns a record of type rt with the values of its fields.
+func (rt *RecordType) New(values []Object) (*Record, error) {
+	if len(values) != len(rt.Fields) {
+		return nil, fmt.Errorf("wrong number of arguments to %s: want=%d, got=%d", rt.Name, len(rt.Fields), len(values))
+	}
+	return &Record{RecordType: rt, Values: append([]Object(nil), values...)}, nil
+}
+
+// Record is an immutable value with the fields of its RecordType, which are read as
+// record.field.  Like a tuple, a record can be a hash key if its values can.
+type Record struct {
+	RecordType *RecordType
+	Values     []Object // of the fields, in order
+}
+
+func (r *Record) Type() ObjectType { return RECORD_OBJ }
+func (r *Record) Inspect() string {
+	var out bytes.Buffer
+	out.WriteString(r.RecordType.Name)
+	out.WriteString("{")
+	for i, field := range r.RecordType.Fields {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(field + ": " + r.Values[i].Inspect())
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+// Field returns the value of the named field of r.
+func (r *Record) Field(name string) (Object, error) {
+	for i, field := range r.RecordType.Fields {
+		if field == name {
+			return r.Values[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s has no field %s", r.RecordType.Name, name)
+}
+
+// sameRecordType reports whether records of types a and b are alike: declared with the same
+// name and fields, even by different evaluations of the declaration.
+func sameRecordType(a, b *RecordType) bool {
+	return a == b || (a.Name == b.Name && slices.Equal(a.Fields, b.Fields))
+}
+
+// HashKeyOf returns the hash key of obj, reporting whether obj can be a hash key or set
+// element: whether it's Hashable, or a tuple or record whose elements are.
+func HashKeyOf(obj Object) (HashKey, bool) {
+	switch obj := obj.(type) {
+	case Hashable:
+		return obj.HashKey(), true
+	case *Tuple:
+		return combineHashKeys(TUPLE_OBJ, "", obj.Elements)
+	case *Record:
+		return combineHashKeys(RECORD_OBJ, obj.RecordType.Name, obj.Values)
+	}
+	return HashKey{}, false
+}
+
+func combineHashKeys(t ObjectType, name string, elements []Object) (HashKey, bool) {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	for _, el := range elements {
+		key, ok := HashKeyOf(el)
+		if !ok {
+			return HashKey{}, false
+		}
+		fmt.Fprintf(h, "|%d:%d", key.Type, key.Value)
+	}
+	return HashKey{Type: t, Value: h.Sum64()}, true
+}
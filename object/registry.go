@@ -0,0 +1,173 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// Registry holds the builtins a program can call, by name.  Compiled programs refer to
+// builtins by name, so a program runs under any registry that defines the builtins it uses.
+//
+// A nil *Registry holds the standard builtins.  A Registry mustn't be changed while programs
+// using it run.
+type Registry struct {
+	names    []string
+	builtins map[string]*Builtin
+}
+
+var standard = NewRegistry()
+
+// NewRegistry returns a registry holding the standard builtins.
+func NewRegistry() *Registry {
+	r := &Registry{builtins: make(map[string]*Builtin, len(Builtins))}
+	for _, def := range Builtins {
+		r.Define(def.Name, def.Builtin)
+	}
+	return r
+}
+
+// Define adds b to r as name, replacing any builtin already called name.
+func (r *Registry) Define(name string, b *Builtin) {
+	if _, ok := r.builtins[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.builtins[name] = b
+}
+
+// Register adds the Go function fn to r as name.  Arguments are converted to the types of
+// fn's parameters by the rules of ToGo, and its result back to an object by those of FromGo.
+//
+// fn may take a context.Context first, which is the calling task's context, and may be
+// variadic.  It may return nothing, a value, an error, or a value and an error.  A non-nil
+// error is returned to the program as an error object.
+func (r *Registry) Register(name string, fn any) error {
+	b, err := goBuiltin(name, fn)
+	if err != nil {
+		return fmt.Errorf("register %s: %w", name, err)
+	}
+	r.Define(name, b)
+	return nil
+}
+
+// Lookup returns the builtin called name.
+func (r *Registry) Lookup(name string) (*Builtin, bool) {
+	if r == nil {
+		r = standard
+	}
+	b, ok := r.builtins[name]
+	return b, ok
+}
+
+// Names returns the names of r's builtins in the order they were defined.
+func (r *Registry) Names() []string {
+	if r == nil {
+		r = standard
+	}
+	return slices.Clone(r.names)
+}
+
+type registryKey struct{}
+
+// WithRegistry returns a context that runs programs with the builtins in r.
+func WithRegistry(ctx context.Context, r *Registry) context.Context {
+	return context.WithValue(ctx, registryKey{}, r)
+}
+
+// RegistryFromContext returns the builtins of the running program, or nil for the standard
+// builtins.
+func RegistryFromContext(ctx context.Context) *Registry {
+	r, _ := ctx.Value(registryKey{}).(*Registry)
+	return r
+}
+
+var (
+	contextType = reflect.TypeFor[context.Context]()
+	errorType   = reflect.TypeFor[error]()
+	objectType  = reflect.TypeFor[Object]()
+)
+
+// goBuiltin wraps fn as a builtin, converting as described by Register.
+func goBuiltin(name string, fn any) (*Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("%T is not a function", fn)
+	}
+	t := v.Type()
+
+	first := 0
+	if t.NumIn() > 0 && t.In(0) == contextType {
+		first = 1
+	}
+	params := make([]reflect.Type, 0, t.NumIn()-first)
+	for i := first; i < t.NumIn(); i++ {
+		params = append(params, t.In(i))
+	}
+	var variadic reflect.Type
+	if t.IsVariadic() {
+		variadic = params[len(params)-1].Elem()
+		params = params[:len(params)-1]
+	}
+	for _, p := range params {
+		if !convertible(p) {
+			return nil, fmt.Errorf("unsupported parameter type %s", p)
+		}
+	}
+	if variadic != nil && !convertible(variadic) {
+		return nil, fmt.Errorf("unsupported parameter type %s", variadic)
+	}
+
+	hasValue, hasErr := false, false
+	switch {
+	case t.NumOut() == 0:
+	case t.NumOut() == 1 && t.Out(0) == errorType:
+		hasErr = true
+	case t.NumOut() == 1:
+		hasValue = true
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+		hasValue, hasErr = true, true
+	default:
+		return nil, fmt.Errorf("%s must return a value, an error, or both", t)
+	}
+	if hasValue && !convertible(t.Out(0)) {
+		return nil, fmt.Errorf("unsupported result type %s", t.Out(0))
+	}
+
+	return &Builtin{Fn: func(ctx context.Context, args ...Object) Object {
+		if len(args) < len(params) || variadic == nil && len(args) > len(params) {
+			return newError("wrong number of arguments, got=%d, want=%d", len(args), len(params))
+		}
+
+		in := make([]reflect.Value, 0, first+len(args))
+		if first == 1 {
+			in = append(in, reflect.ValueOf(ctx))
+		}
+		for i, arg := range args {
+			p := variadic
+			if i < len(params) {
+				p = params[i]
+			}
+			val := reflect.New(p).Elem()
+			if err := toGo(arg, val, map[visit]bool{}); err != nil {
+				return newError("argument %d to '%s' %s", i+1, name, err)
+			}
+			in = append(in, val)
+		}
+
+		out := v.Call(in)
+		if hasErr {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return newError("%s", err)
+			}
+		}
+		if !hasValue {
+			return NULL
+		}
+		obj, err := fromGo(out[0], map[visit]bool{})
+		if err != nil {
+			return newError("result of '%s': %s", name, err)
+		}
+		return allocated(ctx, obj)
+	}}, nil
+}
@@ -0,0 +1,147 @@
+package object
+
+import "math"
+
+// ResolveIndex maps idx onto a sequence of the given length, counting negative indexes back
+// from the end.  It returns false when the index is out of range.
+func ResolveIndex(idx int64, length int) (int, bool) {
+	if idx < 0 {
+		idx += int64(length)
+	}
+	if idx < 0 || idx >= int64(length) {
+		return 0, false
+	}
+	return int(idx), true
+}
+
+// SliceBounds resolves the start and end of seq[start:end] against a sequence of the given
+// length.  Either bound may be NULL when it was omitted.  Like Python, negative bounds count
+// back from the end and out of range bounds are clamped, so the result is always a valid
+// (possibly empty) half open range.
+func SliceBounds(start, end Object, length int) (int, int, *Error) {
+	lo, err := sliceBound(start, 0, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, err := sliceBound(end, length, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	if hi < lo {
+		hi = lo
+	}
+	return lo, hi, nil
+}
+
+func sliceBound(bound Object, omitted, length int) (int, *Error) {
+	switch b := bound.(type) {
+	case *Null:
+		return omitted, nil
+	case *Integer:
+		idx := b.Value
+		if idx < 0 {
+			idx += int64(length)
+		}
+		if idx < 0 {
+			return 0, nil
+		}
+		if idx > int64(length) {
+			return length, nil
+		}
+		return int(idx), nil
+	default:
+		return 0, newError("slice index must be INTEGER, got %s", bound.Type())
+	}
+}
+
+// Slice returns left[start:end] for arrays, tuples, strings, bytes and ranges.  Strings are
+// sliced by rune.
+func Slice(left, start, end Object) Object {
+	switch l := left.(type) {
+	case *Array:
+		lo, hi, err := SliceBounds(start, end, len(l.Elements))
+		if err != nil {
+			return err
+		}
+		elements := make([]Object, hi-lo)
+		copy(elements, l.Elements[lo:hi])
+		return &Array{Elements: elements}
+	case *Tuple:
+		lo, hi, err := SliceBounds(start, end, len(l.Elements))
+		if err != nil {
+			return err
+		}
+		return &Tuple{Elements: l.Elements[lo:hi:hi]}
+	case *Range:
+		return sliceRange(l, start, end)
+	case *String:
+		runes := []rune(l.Value)
+		lo, hi, err := SliceBounds(start, end, len(runes))
+		if err != nil {
+			return err
+		}
+		return &String{Value: string(runes[lo:hi])}
+	case *Bytes:
+		lo, hi, err := SliceBounds(start, end, len(l.Value))
+		if err != nil {
+			return err
+		}
+		return &Bytes{Value: l.Value[lo:hi:hi]}
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+}
+
+// sliceRange returns r[start:end] as a range written the same way as r, a..b or a..=b,
+// without computing bounds past the ends of r, which may be math.MinInt64 or math.MaxInt64.
+func sliceRange(r *Range, start, end Object) Object {
+	n, ok := r.Len()
+	if !ok {
+		return newError("can't slice %s, it has more than %d elements", r.Inspect(), int64(math.MaxInt64))
+	}
+	lo, hi, err := SliceBounds(start, end, int(n))
+	if err != nil {
+		return err
+	}
+	first := r.Start + int64(lo)
+	if lo == int(n) {
+		// lo is past the last element; any empty range will do
+		first = r.End
+	}
+	switch {
+	case hi == lo:
+		return &Range{Start: first, End: first}
+	case r.Inclusive:
+		return &Range{Start: first, End: r.Start + int64(hi) - 1, Inclusive: true}
+	default:
+		return &Range{Start: first, End: r.Start + int64(hi)}
+	}
+}
+
+// StringIndex returns the rune at idx as a string.  It returns false when idx is out of range.
+func StringIndex(str *String, idx int64) (*String, bool) {
+	runes := []rune(str.Value)
+	i, ok := ResolveIndex(idx, len(runes))
+	if !ok {
+		return nil, false
+	}
+	return &String{Value: string(runes[i])}, true
+}
+
+// RangeIndex returns the integer at idx in the range.  It returns false when idx is out of range.
+func RangeIndex(r *Range, idx int64) (*Integer, bool) {
+	n, ok := r.Len()
+	if !ok {
+		// every int64 index is in range, and negative ones count back from the last element
+		if idx >= 0 {
+			return &Integer{Value: r.Start + idx}, true
+		}
+		last, _ := r.Last()
+		return &Integer{Value: last + (idx + 1)}, true
+	}
+	i, ok := ResolveIndex(idx, int(n))
+	if !ok {
+		return nil, false
+	}
+	return &Integer{Value: r.Start + int64(i)}, true
+}
@@ -0,0 +1,216 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Set is a collection of distinct hashable values, kept in the order they were first added.
+// The zero Set is empty.
+type Set struct {
+	index    map[HashKey][]int // positions in elements of the elements with each HashKey
+	elements []Object
+}
+
+func (s *Set) Type() ObjectType { return SET_OBJ }
+func (s *Set) Inspect() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range s.elements {
+		elements = append(elements, el.Inspect())
+	}
+
+	out.WriteString("#{")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+
+// Add adds obj to s if it isn't already in it.
+func (s *Set) Add(obj Object) error {
+	hashKey, ok := HashKeyOf(obj)
+	if !ok {
+		return fmt.Errorf("unusable as set element: %s", obj.Type())
+	}
+	if s.has(hashKey, obj) {
+		return nil
+	}
+	if s.index == nil {
+		s.index = make(map[HashKey][]int)
+	}
+	s.index[hashKey] = append(s.index[hashKey], len(s.elements))
+	s.elements = append(s.elements, obj)
+	return nil
+}
+
+// Has reports whether obj is in s.
+func (s *Set) Has(obj Object) bool {
+	hashKey, ok := HashKeyOf(obj)
+	return ok && s.has(hashKey, obj)
+}
+
+// has reports whether obj, whose HashKey is hashKey, is in s.
+func (s *Set) has(hashKey HashKey, obj Object) bool {
+	for _, i := range s.index[hashKey] {
+		if sameKey(s.elements[i], obj) {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of elements in s.
+func (s *Set) Len() int {
+	return len(s.elements)
+}
+
+// Elements returns the elements of s in order, which mustn't be modified.
+func (s *Set) Elements() []Object {
+	return s.elements
+}
+
+// The set builtins return new sets, leaving their arguments unchanged.
+
+// setFn returns a set of the values of an array or other iterable, or an empty set: set(xs?).
+func setFn(ctx context.Context, args ...Object) Object {
+	if len(args) > 1 {
+		return newError("wrong number of arguments. got=%d, want=0 to 1", len(args))
+	}
+	result := &Set{}
+	if len(args) == 1 {
+		values, err := iterableValues(ctx, "set", args[0])
+		if err != nil {
+			return err
+		}
+		for _, value := range values {
+			if err := result.Add(value); err != nil {
+				return newError("%s", err)
+			}
+		}
+	}
+	return allocated(ctx, result)
+}
+
+// add returns a set with a value added: add(s, x).
+func add(ctx context.Context, args ...Object) Object {
+	s, err := setArgs("add", args, 2)
+	if err != nil {
+		return err
+	}
+	result := s[0].copy()
+	if err := result.Add(args[1]); err != nil {
+		return newError("%s", err)
+	}
+	return allocated(ctx, result)
+}
+
+// remove returns a set without a value: remove(s, x).
+func remove(ctx context.Context, args ...Object) Object {
+	s, err := setArgs("remove", args, 2)
+	if err != nil {
+		return err
+	}
+	if _, ok := HashKeyOf(args[1]); !ok {
+		return newError("unusable as set element: %s", args[1].Type())
+	}
+	result := &Set{}
+	for _, el := range s[0].elements {
+		if !sameKey(el, args[1]) {
+			result.Add(el)
+		}
+	}
+	return allocated(ctx, result)
+}
+
+// union returns the values in any of its sets: union(s, t, ...).
+func union(ctx context.Context, args ...Object) Object {
+	sets, err := setArgs("union", args, -1)
+	if err != nil {
+		return err
+	}
+	result := &Set{}
+	for _, s := range sets {
+		for _, el := range s.elements {
+			result.Add(el)
+		}
+	}
+	return allocated(ctx, result)
+}
+
+// intersection returns the values of the first set that are in all the others:
+// intersection(s, t, ...).
+func intersection(ctx context.Context, args ...Object) Object {
+	sets, err := setArgs("intersection", args, -1)
+	if err != nil {
+		return err
+	}
+	return allocated(ctx, sets[0].filter(func(el Object) bool {
+		for _, s := range sets[1:] {
+			if !s.Has(el) {
+				return false
+			}
+		}
+		return true
+	}))
+}
+
+// difference returns the values of the first set that aren't in any of the others:
+// difference(s, t, ...).
+func difference(ctx context.Context, args ...Object) Object {
+	sets, err := setArgs("difference", args, -1)
+	if err != nil {
+		return err
+	}
+	return allocated(ctx, sets[0].filter(func(el Object) bool {
+		for _, s := range sets[1:] {
+			if s.Has(el) {
+				return false
+			}
+		}
+		return true
+	}))
+}
+
+// setArgs checks the arguments of a builtin taking sets: n arguments whose first is a set, or
+// with n < 0, at least one argument all of which are sets.
+func setArgs(name string, args []Object, n int) ([]*Set, Object) {
+	if n >= 0 && len(args) != n {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args), n)
+	}
+	if len(args) == 0 {
+		return nil, newError("wrong number of arguments. got=0, want at least 1")
+	}
+	if n >= 0 {
+		args = args[:1]
+	}
+	sets := make([]*Set, len(args))
+	for i, arg := range args {
+		s, ok := arg.(*Set)
+		if !ok {
+			return nil, newError("argument %d to '%s' must be SET, got %s", i+1, name, arg.Type())
+		}
+		sets[i] = s
+	}
+	return sets, nil
+}
+
+func (s *Set) copy() *Set {
+	index := make(map[HashKey][]int, len(s.index))
+	for hashKey, bucket := range s.index {
+		index[hashKey] = slices.Clip(bucket)
+	}
+	return &Set{index: index, elements: slices.Clone(s.elements)}
+}
+
+func (s *Set) filter(keep func(Object) bool) *Set {
+	result := &Set{}
+	for _, el := range s.elements {
+		if keep(el) {
+			result.Add(el)
+		}
+	}
+	return result
+}
@@ -0,0 +1,222 @@
+package object
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stringsNamespace holds the string functions, called as strings.name(args).  Lengths,
+// indexes and widths count characters (runes), not bytes.
+var stringsNamespace = NewNamespace("strings", map[string]*Builtin{
+	"split":       {Fn: split},
+	"join":        {Fn: join},
+	"trim":        {Fn: trimmer("trim", strings.TrimSpace, strings.Trim)},
+	"trim_left":   {Fn: trimmer("trim_left", trimLeftSpace, strings.TrimLeft)},
+	"trim_right":  {Fn: trimmer("trim_right", trimRightSpace, strings.TrimRight)},
+	"upper":       {Fn: mapString("upper", strings.ToUpper)},
+	"lower":       {Fn: mapString("lower", strings.ToLower)},
+	"contains":    {Fn: testString("contains", strings.Contains)},
+	"starts_with": {Fn: testString("starts_with", strings.HasPrefix)},
+	"ends_with":   {Fn: testString("ends_with", strings.HasSuffix)},
+	"index_of":    {Fn: indexOf},
+	"replace":     {Fn: replace},
+	"repeat":      {Fn: repeat},
+	"pad_left":    {Fn: padder("pad_left", true)},
+	"pad_right":   {Fn: padder("pad_right", false)},
+	"chars":       {Fn: chars},
+	"bytes":       {Fn: stringBytes},
+})
+
+// checkArgs checks the number and types of the arguments to the builtin name, of which the
+// first required are required.
+func checkArgs(name string, args []Object, required int, types ...ObjectType) Object {
+	if len(args) < required || len(args) > len(types) {
+		if required == len(types) {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), required)
+		}
+		return newError("wrong number of arguments. got=%d, want=%d to %d", len(args), required, len(types))
+	}
+	for i, arg := range args {
+		if arg.Type() != types[i] {
+			return newError("argument %d to '%s' must be %s, got %s", i+1, name, types[i], arg.Type())
+		}
+	}
+	return nil
+}
+
+func split(ctx context.Context, args ...Object) Object {
+	if err := checkArgs("split", args, 2, STRING_OBJ, STRING_OBJ); err != nil {
+		return err
+	}
+	parts := strings.Split(args[0].(*String).Value, args[1].(*String).Value)
+	return allocated(ctx, stringArray(parts))
+}
+
+func join(ctx context.Context, args ...Object) Object {
+	if err := checkArgs("join", args, 2, ARRAY_OBJ, STRING_OBJ); err != nil {
+		return err
+	}
+	elements := args[0].(*Array).Elements
+	parts := make([]string, len(elements))
+	for i, el := range elements {
+		s, ok := el.(*String)
+		if !ok {
+			return newError("elements of the array passed to 'join' must be STRING, got %s", el.Type())
+		}
+		parts[i] = s.Value
+	}
+	return allocated(ctx, &String{Value: strings.Join(parts, args[1].(*String).Value)})
+}
+
+// trimmer returns a builtin trimming whitespace, or the characters in an optional cutset.
+func trimmer(name string, space func(string) string, cutset func(string, string) string) BuiltinFunction {
+	return func(ctx context.Context, args ...Object) Object {
+		if err := checkArgs(name, args, 1, STRING_OBJ, STRING_OBJ); err != nil {
+			return err
+		}
+		s := args[0].(*String).Value
+		if len(args) == 1 {
+			return &String{Value: space(s)}
+		}
+		return &String{Value: cutset(s, args[1].(*String).Value)}
+	}
+}
+
+func trimLeftSpace(s string) string  { return strings.TrimLeftFunc(s, unicode.IsSpace) }
+func trimRightSpace(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) }
+
+func mapString(name string, fn func(string) string) BuiltinFunction {
+	return func(ctx context.Context, args ...Object) Object {
+		if err := checkArgs(name, args, 1, STRING_OBJ); err != nil {
+			return err
+		}
+		return allocated(ctx, &String{Value: fn(args[0].(*String).Value)})
+	}
+}
+
+func testString(name string, fn func(string, string) bool) BuiltinFunction {
+	return func(ctx context.Context, args ...Object) Object {
+		if err := checkArgs(name, args, 2, STRING_OBJ, STRING_OBJ); err != nil {
+			return err
+		}
+		return nativeBool(fn(args[0].(*String).Value, args[1].(*String).Value))
+	}
+}
+
+// indexOf returns the character index of the first instance of sub in s, or -1.
+func indexOf(ctx context.Context, args ...Object) Object {
+	if err := checkArgs("index_of", args, 2, STRING_OBJ, STRING_OBJ); err != nil {
+		return err
+	}
+	s := args[0].(*String).Value
+	i := strings.Index(s, args[1].(*String).Value)
+	if i > 0 {
+		i = utf8.RuneCountInString(s[:i])
+	}
+	return &Integer{Value: int64(i)}
+}
+
+// replace replaces every instance of old in s with new, or the first n: replace(s, old, new, n?).
+func replace(ctx context.Context, args ...Object) Object {
+	if err := checkArgs("replace", args, 3, STRING_OBJ, STRING_OBJ, STRING_OBJ, INTEGER_OBJ); err != nil {
+		return err
+	}
+	n := -1
+	if len(args) == 4 {
+		n = int(args[3].(*Integer).Value)
+	}
+	s, old, replacement := args[0].(*String).Value, args[1].(*String).Value, args[2].(*String).Value
+	return allocated(ctx, &String{Value: strings.Replace(s, old, replacement, n)})
+}
+
+// maxStringBytes bounds the strings repeat and the padders build.  Checking counts against it
+// before multiplying keeps them from overflowing, and keeps huge results below the largest
+// allocation Go supports, so they're errors rather than panics when no limits are set.
+const maxStringBytes = 1 << 40
+
+func repeat(ctx context.Context, args ...Object) Object {
+	if err := checkArgs("repeat", args, 2, STRING_OBJ, INTEGER_OBJ); err != nil {
+		return err
+	}
+	s, n := args[0].(*String).Value, args[1].(*Integer).Value
+	if n < 0 {
+		return newError("negative count passed to 'repeat': %d", n)
+	}
+	if len(s) > 0 && n > maxStringBytes/int64(len(s)) {
+		return newError("count passed to 'repeat' is too large: %d", n)
+	}
+	if budget := BudgetFromContext(ctx); !budget.fits(int64(len(s)) * n) {
+		return NewLimitError("MaxAllocatedBytes", budget.Limits().MaxAllocatedBytes)
+	}
+	return allocated(ctx, &String{Value: strings.Repeat(s, int(n))})
+}
+
+// padder returns a builtin padding a string to a width with spaces, or an optional
+// character: pad_left(s, width, pad?).
+func padder(name string, left bool) BuiltinFunction {
+	return func(ctx context.Context, args ...Object) Object {
+		if err := checkArgs(name, args, 2, STRING_OBJ, INTEGER_OBJ, STRING_OBJ); err != nil {
+			return err
+		}
+		s, width := args[0].(*String).Value, args[1].(*Integer).Value
+		pad := " "
+		if len(args) == 3 {
+			pad = args[2].(*String).Value
+			if utf8.RuneCountInString(pad) != 1 {
+				return newError("pad passed to '%s' must be one character, got %q", name, pad)
+			}
+		}
+
+		n := width - int64(utf8.RuneCountInString(s))
+		if n <= 0 {
+			return args[0]
+		}
+		if n > (maxStringBytes-int64(len(s)))/int64(len(pad)) {
+			return newError("width passed to '%s' is too large: %d", name, width)
+		}
+		if budget := BudgetFromContext(ctx); !budget.fits(int64(len(pad)) * n) {
+			return NewLimitError("MaxAllocatedBytes", budget.Limits().MaxAllocatedBytes)
+		}
+		padding := strings.Repeat(pad, int(n))
+		if left {
+			return allocated(ctx, &String{Value: padding + s})
+		}
+		return allocated(ctx, &String{Value: s + padding})
+	}
+}
+
+// chars returns the characters of a string as strings.
+func chars(ctx context.Context, args ...Object) Object {
+	if err := checkArgs("chars", args, 1, STRING_OBJ); err != nil {
+		return err
+	}
+	s := args[0].(*String).Value
+	parts := make([]string, 0, len(s))
+	for _, r := range s {
+		parts = append(parts, string(r))
+	}
+	return allocated(ctx, stringArray(parts))
+}
+
+// stringBytes returns the UTF-8 encoding of a string as integers.
+func stringBytes(ctx context.Context, args ...Object) Object {
+	if err := checkArgs("bytes", args, 1, STRING_OBJ); err != nil {
+		return err
+	}
+	s := args[0].(*String).Value
+	elements := make([]Object, len(s))
+	for i := 0; i < len(s); i++ {
+		elements[i] = &Integer{Value: int64(s[i])}
+	}
+	return allocated(ctx, &Array{Elements: elements})
+}
+
+func stringArray(parts []string) *Array {
+	elements := make([]Object, len(parts))
+	for i, part := range parts {
+		elements[i] = &String{Value: part}
+	}
+	return &Array{Elements: elements}
+}
@@ -0,0 +1,243 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Scheduler is shared by the tasks of one program run.  It tracks which tasks are parked
+// in a channel operation or waiting for another task, so that when every live task is
+// blocked it can wake them all with a deadlock error instead of hanging.  Channel state is
+// guarded by the same lock, so a task handed a value is unblocked atomically with the hand off.
+type Scheduler struct {
+	mu      sync.Mutex
+	nextID  int
+	live    int
+	blocked map[*Task]*waiter
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{blocked: make(map[*Task]*waiter)}
+}
+
+// detached schedules the channels made by Go code outside of any task.
+var detached = NewScheduler()
+
+// SchedulerFromContext returns the scheduler of the task calling with ctx, or the detached
+// scheduler outside of a task.
+func SchedulerFromContext(ctx context.Context) *Scheduler {
+	if t := TaskFromContext(ctx); t != nil {
+		return t.sched
+	}
+	return detached
+}
+
+// waiter is a task parked in a blocking operation.  A select parks one waiter on several
+// channels; the first case to complete fires it and the others skip it.
+type waiter struct {
+	task      *Task
+	desc      string
+	signal    chan struct{}
+	fired     bool
+	caseIndex int
+	value     Object // the value received, or the result of a waited for task
+	ok        bool
+	err       *Error
+}
+
+func newWaiter(t *Task, desc string) *waiter {
+	return &waiter{task: t, desc: desc, signal: make(chan struct{}, 1)}
+}
+
+// fire completes the waiter's operation and wakes it.  The scheduler lock must be held.
+func (s *Scheduler) fire(w *waiter) {
+	w.fired = true
+	if w.task != nil {
+		delete(s.blocked, w.task)
+	}
+	w.signal <- struct{}{}
+}
+
+// park blocks until w fires or ctx is done.  The scheduler lock must be held on entry and
+// is released.
+func (s *Scheduler) park(ctx context.Context, w *waiter) {
+	if w.task != nil {
+		s.blocked[w.task] = w
+		s.checkDeadlock()
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-w.signal:
+	case <-ctx.Done():
+		s.mu.Lock()
+		if !w.fired {
+			w.err = NewInterruptError(ctx)
+			s.fire(w)
+		}
+		s.mu.Unlock()
+		<-w.signal
+	}
+	// a run that's out of time reports that, not the deadlock left by the tasks it stopped
+	if w.err != nil && ctx.Err() != nil {
+		w.err = NewInterruptError(ctx)
+	}
+}
+
+// checkDeadlock wakes every blocked task with an error when no live task can make progress.
+// The scheduler lock must be held.
+func (s *Scheduler) checkDeadlock() {
+	if s.live == 0 || len(s.blocked) < s.live {
+		return
+	}
+
+	waiters := make([]*waiter, 0, len(s.blocked))
+	for _, w := range s.blocked {
+		waiters = append(waiters, w)
+	}
+	sort.Slice(waiters, func(i, j int) bool { return waiters[i].task.ID < waiters[j].task.ID })
+
+	blocked := make([]string, len(waiters))
+	for i, w := range waiters {
+		blocked[i] = fmt.Sprintf("%s (%s)", w.task.Name, w.desc)
+	}
+	err := newError("deadlock: all tasks are blocked: %s", strings.Join(blocked, ", "))
+	for _, w := range waiters {
+		w.err = err
+		s.fire(w)
+	}
+}
+
+// Task is a thread of Monkey execution: the main program, or a function started with spawn.
+type Task struct {
+	ID       int
+	Name     string
+	started  bool
+	finished bool
+	result   Object
+	joiners  []*waiter
+	depth    int // call depth, only used by the goroutine running the task
+	sched    *Scheduler
+}
+
+// NewTask returns a task scheduled by s, named name or numbered if name is empty.
+func (s *Scheduler) NewTask(name string) *Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &Task{ID: s.nextID, Name: name, sched: s}
+	s.nextID++
+	if t.Name == "" {
+		t.Name = fmt.Sprintf("task %d", t.ID)
+	}
+	return t
+}
+
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) Inspect() string  { return fmt.Sprintf("Task[%s]", t.Name) }
+
+// Start counts the task as live.  Call it before the task's goroutine is started, so a
+// parent that blocks immediately can't be mistaken for the last live task.
+func (t *Task) Start() {
+	t.sched.mu.Lock()
+	defer t.sched.mu.Unlock()
+	if t.started {
+		return
+	}
+	t.started = true
+	t.sched.live++
+}
+
+// Finish records the task's result and wakes the tasks waiting for it.
+func (t *Task) Finish(result Object) {
+	t.sched.mu.Lock()
+	defer t.sched.mu.Unlock()
+	if !t.started || t.finished {
+		return
+	}
+	if result == nil {
+		result = NULL
+	}
+	t.finished = true
+	t.result = result
+	t.sched.live--
+	for _, w := range t.joiners {
+		if !w.fired {
+			w.value = result
+			t.sched.fire(w)
+		}
+	}
+	t.joiners = nil
+	t.sched.checkDeadlock()
+}
+
+// Wait blocks the task calling with ctx until t finishes, and returns t's result.
+func (t *Task) Wait(ctx context.Context) Object {
+	caller := TaskFromContext(ctx)
+	t.sched.mu.Lock()
+	if t.finished {
+		t.sched.mu.Unlock()
+		return t.result
+	}
+	if caller == t {
+		t.sched.mu.Unlock()
+		return newError("task can't wait for itself")
+	}
+
+	w := newWaiter(caller, "wait "+t.Name)
+	t.joiners = append(t.joiners, w)
+	t.sched.park(ctx, w)
+	if w.err != nil {
+		return w.err
+	}
+	return w.value
+}
+
+// EnterCall records a function call made by the task and returns the new call depth.
+// Tree walking engines track depth here; the VM uses its frames.
+func (t *Task) EnterCall() int {
+	t.depth++
+	return t.depth
+}
+
+func (t *Task) ExitCall() {
+	t.depth--
+}
+
+func (t *Task) Method(name string) (*Builtin, bool) {
+	switch name {
+	case "wait":
+		return &Builtin{Fn: func(ctx context.Context, args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return t.Wait(ctx)
+		}}, true
+	case "done":
+		return &Builtin{Fn: func(ctx context.Context, args ...Object) Object {
+			t.sched.mu.Lock()
+			defer t.sched.mu.Unlock()
+			if t.finished {
+				return TRUE
+			}
+			return FALSE
+		}}, true
+	}
+	return nil, false
+}
+
+type taskKey struct{}
+
+// WithTask returns a context that identifies t as the task calling builtins.
+func WithTask(ctx context.Context, t *Task) context.Context {
+	return context.WithValue(ctx, taskKey{}, t)
+}
+
+// TaskFromContext returns the task running a builtin.  Builtins called from Go code outside
+// of a task get nil, and their blocking operations aren't considered by deadlock detection.
+func TaskFromContext(ctx context.Context) *Task {
+	t, _ := ctx.Value(taskKey{}).(*Task)
+	return t
+}
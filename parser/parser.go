@@ -1,11 +1,14 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 type (
@@ -16,36 +19,63 @@ type (
 const (
 	_ int = iota
 	LOWEST
+	PIPE    // x |> f
+	COMPOSE // f >> g
+	TERNARY // c ? x : y
+	COALESCE
 	EQUALS
 	LESSGREATER
+	RANGE // a..b
 	SUM
 	PRODUCT
 	PREFIX
+	POWER // a ** b, binding tighter than -a
 	CALL
 	INDEX // array[index]
 )
 
 var precedences = map[token.TokenType]int{
+	token.PIPE:     PIPE,
+	token.COMPOSE:  COMPOSE,
+	token.QUESTION: TERNARY,
+	token.COALESCE: COALESCE,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.RANGE:    RANGE,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.POWER:    POWER,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
+
+	token.RANGE_INCLUSIVE: RANGE,
+	token.OPTIONAL_DOT:    INDEX,
 }
 
 type Parser struct {
 	l         *lexer.Lexer
 	curToken  token.Token
 	peekToken token.Token
+	ahead     []token.Token // tokens read past peekToken by tokenAt
 	errors    []string
 
+	// depth counts the brackets open after curToken, and colons holds the depths at which
+	// a conditional, hash key or index being parsed still expects a ':'.  Together they tell
+	// a?[k] from c ? [x] : y; see optionalIndexAt.
+	depth  int
+	colons []int
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// functions holds the function literals being parsed, innermost last, so a yield can
+	// mark the function that contains it as a generator.
+	functions []*ast.FunctionLiteral
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -60,26 +90,40 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.DECIMAL, p.parseDecimalLiteral)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.BYTES, p.parseBytesLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.SET_BRACE, p.parseSetLiteral)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
+	p.registerPrefix(token.SPAWN, p.parseSpawnExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.PIPE, p.parsePipeExpression)
+	p.registerInfix(token.COMPOSE, p.parseComposeExpression)
+	p.registerInfix(token.QUESTION, p.parseQuestionExpression)
+	p.registerInfix(token.COALESCE, p.parseCoalesceExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.RANGE, p.parseRangeExpression)
+	p.registerInfix(token.RANGE_INCLUSIVE, p.parseRangeExpression)
+	p.registerInfix(token.OPTIONAL_DOT, p.parseMemberExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -96,7 +140,105 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	if len(p.ahead) > 0 {
+		p.peekToken = p.ahead[0]
+		p.ahead = p.ahead[1:]
+	} else {
+		p.peekToken = p.l.NextToken()
+	}
+
+	switch p.curToken.Type {
+	case token.LPAREN, token.LBRACKET, token.LBRACE, token.SET_BRACE:
+		p.depth++
+	case token.RPAREN, token.RBRACKET, token.RBRACE:
+		p.depth--
+	}
+}
+
+// tokenAt returns the token n tokens past curToken, so tokenAt(0) is curToken and tokenAt(1)
+// is peekToken.
+func (p *Parser) tokenAt(n int) token.Token {
+	switch n {
+	case 0:
+		return p.curToken
+	case 1:
+		return p.peekToken
+	}
+	for len(p.ahead) < n-1 {
+		p.ahead = append(p.ahead, p.l.NextToken())
+	}
+	return p.ahead[n-2]
+}
+
+// expectColon records that the construct being parsed at the current depth ends its next
+// part with a ':', until the returned function is called.
+func (p *Parser) expectColon() func() {
+	p.colons = append(p.colons, p.depth)
+	return func() { p.colons = p.colons[:len(p.colons)-1] }
+}
+
+// optionalIndexAt reports whether the ? at tokenAt(n) starts an optional index a?[k] rather
+// than a conditional.  A ? followed by [ is a conditional when, after the brackets, more
+// colons follow at this depth than the enclosing conditionals, hash keys and indexes expect
+// and the conditionals in between consume.  A later ? just after an identifier, ] or ) is
+// taken for an optional index, which consumes no colon.
+func (p *Parser) optionalIndexAt(n int) bool {
+	if p.tokenAt(n+1).Type != token.LBRACKET {
+		return false
+	}
+
+	colons := 0
+	for _, depth := range p.colons {
+		if depth == p.depth {
+			colons--
+		}
+	}
+
+	depth := 0
+scan:
+	for i := n + 1; ; i++ {
+		switch p.tokenAt(i).Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE, token.SET_BRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
+			if depth < 0 {
+				break scan
+			}
+		case token.EOF:
+			break scan
+		case token.COLON:
+			if depth == 0 {
+				colons++
+			}
+		case token.QUESTION:
+			// a ? after something it could index, as in b?[k], takes no colon
+			if depth == 0 && !indexable(p.tokenAt(i-1).Type) {
+				colons--
+			}
+		case token.COMMA, token.SEMICOLON, token.LET, token.RETURN:
+			if depth == 0 {
+				break scan
+			}
+		}
+	}
+	return colons <= 0
+}
+
+// indexable reports whether a token of type t can end the receiver of an index.
+func indexable(t token.TokenType) bool {
+	return t == token.IDENT || t == token.RBRACKET || t == token.RPAREN
+}
+
+// isPostfix reports whether peekToken continues a chain of indexes, slices and calls.
+func (p *Parser) isPostfix() bool {
+	switch p.peekToken.Type {
+	case token.LBRACKET, token.DOT, token.LPAREN, token.OPTIONAL_DOT:
+		return true
+	case token.QUESTION:
+		return p.optionalIndexAt(1)
+	}
+	return false
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -122,12 +264,17 @@ func (p *Parser) parseStatement() (ast.Statement, bool) {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.RECORD:
+		return p.parseRecordStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 func (p *Parser) peekPrecedence() int {
+	if p.peekTokenIs(token.QUESTION) && p.optionalIndexAt(1) {
+		return INDEX
+	}
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
 	}
@@ -171,13 +318,74 @@ func (p *Parser) parseLetStatement() (*ast.LetStatement, bool) {
 	return stmt, true
 }
 
+// parseRecordStatement parses record Name { field, ... }.
+func (p *Parser) parseRecordStatement() (*ast.RecordStatement, bool) {
+	stmt := &ast.RecordStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil, false
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.LBRACE) {
+		return nil, false
+	}
+
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil, false
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[field.Value] {
+			msg := fmt.Sprintf("%s duplicate field %s in record %s", p.curToken.LineInfo, field.Value, stmt.Name.Value)
+			p.errors = append(p.errors, msg)
+			return nil, false
+		}
+		seen[field.Value] = true
+		stmt.Fields = append(stmt.Fields, field)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil, false
+		}
+	}
+	p.nextToken()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt, true
+}
+
+// parseGroupedExpression parses (x), or a tuple if there's a comma: (), (x,) or (x, y).
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	tuple := &ast.TupleLiteral{Token: p.curToken, Elements: []ast.Expression{}}
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return tuple
+	}
+
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
+	if !p.peekTokenIs(token.COMMA) {
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		return exp
+	}
+
+	tuple.Elements = append(tuple.Elements, exp)
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
+		p.nextToken()
+		tuple.Elements = append(tuple.Elements, p.parseExpression(LOWEST))
+	}
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
-	return exp
+	return tuple
 }
 
 func (p *Parser) parseExpressionStatement() (*ast.ExpressionStatement, bool) {
@@ -210,11 +418,32 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		}
 		p.nextToken()
 		leftExp = infixFn(leftExp)
+		if !p.isPostfix() && isOptionalChain(leftExp) {
+			leftExp = &ast.OptionalChainExpression{Chain: leftExp}
+		}
+	}
+	// a chain cut short by the precedence, like the callee of spawn a?.f(x), ends here
+	if isOptionalChain(leftExp) {
+		leftExp = &ast.OptionalChainExpression{Chain: leftExp}
 	}
 
 	return leftExp
 }
 
+// isOptionalChain reports whether exp is a chain of indexes, slices and calls with an
+// optional link that hasn't been closed by an OptionalChainExpression yet.
+func isOptionalChain(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.IndexExpression:
+		return exp.Optional || isOptionalChain(exp.Left)
+	case *ast.SliceExpression:
+		return exp.Optional || isOptionalChain(exp.Left)
+	case *ast.CallExpression:
+		return isOptionalChain(exp.Function)
+	}
+	return false
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 	if !p.expectPeek(token.LPAREN) {
@@ -264,6 +493,11 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if value, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.BigIntegerLiteral{Token: p.curToken, Value: value}
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("%s could not parse %q as integer", p.curToken.LineInfo, p.curToken.Literal)
 		p.errors = append(p.errors, msg)
@@ -282,6 +516,17 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	return &ast.FloatLiteral{Token: p.curToken, Value: value}
 }
 
+func (p *Parser) parseDecimalLiteral() ast.Expression {
+	whole, fraction, _ := strings.Cut(strings.TrimSuffix(p.curToken.Literal, "d"), ".")
+	value, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		msg := fmt.Sprintf("%s could not parse %q as decimal", p.curToken.LineInfo, p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	return &ast.DecimalLiteral{Token: p.curToken, Value: value, Scale: len(fraction)}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
@@ -303,6 +548,10 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left:     left,
 	}
 	precedence := p.curPrecedence()
+	if p.curTokenIs(token.POWER) {
+		// ** is right associative: a ** b ** c is a ** (b ** c)
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 	return expression
@@ -335,11 +584,48 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	p.functions = append(p.functions, lit)
 	lit.Body = p.parseBlockStatement()
+	p.functions = p.functions[:len(p.functions)-1]
 
 	return lit
 }
 
+func (p *Parser) parseYieldExpression() ast.Expression {
+	exp := &ast.YieldExpression{Token: p.curToken}
+	if len(p.functions) == 0 {
+		msg := fmt.Sprintf("%s yield outside of a function", p.curToken.LineInfo)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	p.functions[len(p.functions)-1].IsGenerator = true
+
+	switch p.peekToken.Type {
+	case token.SEMICOLON, token.RBRACE, token.RPAREN, token.RBRACKET, token.COMMA, token.EOF:
+		return exp
+	}
+	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST)
+	return exp
+}
+
+// parseSpawnExpression parses spawn f(args), spawn f and spawn(f).  The callee binds tighter
+// than the call, so spawn f(x).wait() waits for the task rather than spawning f(x).wait().
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	exp := &ast.SpawnExpression{Token: p.curToken, Arguments: []ast.Expression{}}
+	p.nextToken()
+
+	exp.Function = p.parseExpression(CALL)
+	if exp.Function == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		exp.Arguments = p.parseExpressionList(token.RPAREN)
+	}
+	return exp
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := make([]*ast.Identifier, 0)
 
@@ -412,12 +698,70 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseBytesLiteral() ast.Expression {
+	value, ok := unescapeBytes(p.curToken.Literal)
+	if !ok {
+		msg := fmt.Sprintf("%s invalid escape in bytes literal b\"%s\"", p.curToken.LineInfo, p.curToken.Literal)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+	return &ast.BytesLiteral{Token: p.curToken, Value: value}
+}
+
+// unescapeBytes returns the bytes of the contents of b"...", whose escapes are \\, \", \n,
+// \r, \t, \0 and \xNN.
+func unescapeBytes(literal string) ([]byte, bool) {
+	value := make([]byte, 0, len(literal))
+	for i := 0; i < len(literal); i++ {
+		c := literal[i]
+		if c != '\\' {
+			value = append(value, c)
+			continue
+		}
+		if i++; i == len(literal) {
+			return nil, false
+		}
+		switch literal[i] {
+		case '\\', '"':
+			c = literal[i]
+		case 'n':
+			c = '\n'
+		case 'r':
+			c = '\r'
+		case 't':
+			c = '\t'
+		case '0':
+			c = 0
+		case 'x':
+			if i+2 >= len(literal) {
+				return nil, false
+			}
+			n, err := strconv.ParseUint(literal[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, false
+			}
+			c = byte(n)
+			i += 2
+		default:
+			return nil, false
+		}
+		value = append(value, c)
+	}
+	return value, true
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 	array.Elements = p.parseExpressionList(token.RBRACKET)
 	return array
 }
 
+func (p *Parser) parseSetLiteral() ast.Expression {
+	set := &ast.SetLiteral{Token: p.curToken}
+	set.Elements = p.parseExpressionList(token.RBRACE)
+	return set
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -442,9 +786,38 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	return p.parseIndex(left, false)
+}
+
+func (p *Parser) parseIndex(left ast.Expression, optional bool) ast.Expression {
+	tok := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		done := p.expectColon()
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+		done()
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		return p.parseSliceExpression(tok, left, index, optional)
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index, Optional: optional}
+}
+
+func (p *Parser) parseSliceExpression(tok token.Token, left, start ast.Expression, optional bool) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start, Optional: optional}
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -452,13 +825,67 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseMemberExpression parses left.name and left?.name as an index by the string "name".
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left, Optional: p.curTokenIs(token.OPTIONAL_DOT)}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Index = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
+// parseQuestionExpression parses left?[k] and left?[i:j], or the conditional left ? x : y.
+func (p *Parser) parseQuestionExpression(left ast.Expression) ast.Expression {
+	if p.optionalIndexAt(0) {
+		p.nextToken()
+		return p.parseIndex(left, true)
+	}
+	return p.parseConditionalExpression(left)
+}
+
+func (p *Parser) parseConditionalExpression(condition ast.Expression) ast.Expression {
+	exp := &ast.ConditionalExpression{Token: p.curToken, Condition: condition}
+	done := p.expectColon()
+	p.nextToken()
+	exp.Consequence = p.parseExpression(LOWEST)
+	done()
+
+	if !p.expectPeek(token.COLON) {
+		return nil
+	}
+
+	// parsing the alternative one level below TERNARY makes the operator right associative
+	p.nextToken()
+	exp.Alternative = p.parseExpression(TERNARY - 1)
+	return exp
+}
+
+func (p *Parser) parseRangeExpression(start ast.Expression) ast.Expression {
+	exp := &ast.RangeExpression{Token: p.curToken, Start: start, Inclusive: p.curTokenIs(token.RANGE_INCLUSIVE)}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	exp.End = p.parseExpression(precedence)
+	return exp
+}
+
+func (p *Parser) parseCoalesceExpression(left ast.Expression) ast.Expression {
+	exp := &ast.CoalesceExpression{Token: p.curToken, Left: left}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	exp.Right = p.parseExpression(precedence)
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
 
 	for !p.peekTokenIs(token.RBRACE) {
+		done := p.expectColon()
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
+		done()
 
 		if !p.expectPeek(token.COLON) {
 			return nil
@@ -467,6 +894,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -478,3 +906,38 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	}
 	return hash
 }
+
+// parsePipeExpression rewrites `x |> f(a)` as the call `f(x, a)`, and `x |> f` as `f(x)`,
+// so the engines compile and evaluate a pipeline as ordinary nested calls.  Only calls
+// written with parentheses receive x as their first argument; calls produced by |> are
+// values that x is applied to.
+func (p *Parser) parsePipeExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	precedence := p.curPrecedence()
+	p.nextToken()
+	right := p.parseExpression(precedence)
+	if right == nil {
+		return nil
+	}
+
+	if call, ok := right.(*ast.CallExpression); ok && call.Token.Type == token.LPAREN {
+		args := make([]ast.Expression, 0, len(call.Arguments)+1)
+		args = append(args, left)
+		args = append(args, call.Arguments...)
+		return &ast.CallExpression{Token: tok, Function: call.Function, Arguments: args}
+	}
+	return &ast.CallExpression{Token: tok, Function: right, Arguments: []ast.Expression{left}}
+}
+
+// parseComposeExpression parses `f >> g`, a function that calls `g(f(x))`.  Both operands are
+// evaluated once, when the composition is built.
+func (p *Parser) parseComposeExpression(left ast.Expression) ast.Expression {
+	expression := &ast.ComposeExpression{Token: p.curToken, First: left}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Second = p.parseExpression(precedence)
+	if expression.Second == nil {
+		return nil
+	}
+	return expression
+}
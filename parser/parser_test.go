@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"strings"
 	"testing"
 )
 
@@ -138,6 +139,38 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "9223372036854775808;"
+	p := New(lexer.NewFromString("test", input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.BigIntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.BigIntegerLiteral.  got %T", stmt.Expression)
+	}
+	if literal.Value.String() != "9223372036854775808" {
+		t.Errorf("literal.Value not 9223372036854775808. got %s", literal.Value)
+	}
+}
+
+func TestDecimalLiteralExpression(t *testing.T) {
+	input := "12.50d;"
+	p := New(lexer.NewFromString("test", input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.DecimalLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.DecimalLiteral.  got %T", stmt.Expression)
+	}
+	if literal.Value.Int64() != 1250 || literal.Scale != 2 {
+		t.Errorf("wrong literal. want 1250e-2, got %se-%d", literal.Value, literal.Scale)
+	}
+}
+
 func TestBooleanExpression(t *testing.T) {
 	input := "true;"
 	p := New(lexer.NewFromString("test", input))
@@ -358,6 +391,10 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a + b - c", "((a + b) - c)"},
 		{"a * b * c", "((a * b) * c)"},
 		{"a * b / c", "((a * b) / c)"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"a ** f(b)[1]", "(a ** (f(b)[1]))"},
 		{"a + b / c", "(a + (b / c))"},
 		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
 		{"3 + 4; -5 * 5", "(3 + 4)((-5) * 5)"},
@@ -375,6 +412,45 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
 		{"a * [1,2,3,4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
 		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a |> f", "f(a)"},
+		{"a |> f |> g(b)", "g(f(a), b)"},
+		{"a + b |> f(c * d)", "f((a + b), (c * d))"},
+		{"a == b |> f", "f((a == b))"},
+		{"a |> f >> g", "(f >> g)(a)"},
+		{"f >> g >> h", "((f >> g) >> h)"},
+		{"a |> (b |> f)", "f(b)(a)"},
+		{"a ? b : c", "(a ? b : c)"},
+		{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
+		{"a == b ? c + 1 : d * 2", "((a == b) ? (c + 1) : (d * 2))"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b == c", "(a ?? (b == c))"},
+		{"a ?? b ? c : d", "((a ?? b) ? c : d)"},
+		{"a.b.c", "((a[b])[c])"},
+		{"a?.b?[c + 1]", "((a?[b])?[(c + 1)])"},
+		{"a.b(c) ?? d", "((a[b])(c) ?? d)"},
+		{"a[1:n + 1]", "(a[1:(n + 1)])"},
+		{"a[:2][1]", "((a[:2])[1])"},
+		{"a[-1:]", "(a[(-1):])"},
+		{"a?[:]", "(a?[:])"},
+		{"c ?[1] : [2]", "(c ? [1] : [2])"},
+		{"c ? a?[1] : b", "(c ? (a?[1]) : b)"},
+		{"c ?[1] ?[2] : [3] : [4]", "(c ? ([1] ? [2] : [3]) : [4])"},
+		{"{a?[1]: b}", "{(a?[1]):b}"},
+		{"f(c ?[1] : [2], a?[1])", "f((c ? [1] : [2]), (a?[1]))"},
+		{"c ? [7] : a?[0]", "(c ? [7] : (a?[0]))"},
+		{"f(c ? [7] : a?[0])", "f((c ? [7] : (a?[0])))"},
+		{"let v = c ? [7] : a?[0]; v", "let v = (c ? [7] : (a?[0]));v"},
+		{"c ? [1] : x ? [2] : f(a)?[3]", "(c ? [1] : (x ? [2] : (f(a)?[3])))"},
+		{"n?.a.b(c)", "((n?[a])[b])(c)"},
+		{"a[b ? 1 : 2]", "(a[(b ? 1 : 2)])"},
+		{"0..n + 1", "(0..(n + 1))"},
+		{"a..=b == c", "((a..=b) == c)"},
+		{"(0..10)[2:]", "((0..10)[2:])"},
+		{"spawn f(a, b + 1)", "(spawn f(a, (b + 1)))"},
+		{"spawn f(x).wait()", "((spawn f(x))[wait])()"},
+		{"spawn(f)", "(spawn f())"},
+		{"spawn a.b(c) ?? d", "((spawn (a[b])(c)) ?? d)"},
+		{"spawn a?.f(x)", "(spawn (a?[f])(x))"},
 	}
 
 	for i, tt := range tests {
@@ -543,6 +619,35 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBytesLiteralExpression(t *testing.T) {
+	input := `b"a\x00\"\\b";`
+	p := New(lexer.NewFromString("test", input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.BytesLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.BytesLiteral, got=%T", stmt.Expression)
+	}
+	if want := "a\x00\"\\b"; string(literal.Value) != want {
+		t.Errorf("literal.Value not %q. got=%q", want, literal.Value)
+	}
+	if literal.String() != `b"a\x00\"\\b"` {
+		t.Errorf("literal.String() wrong. got=%s", literal.String())
+	}
+}
+
+func TestBytesLiteralInvalidEscape(t *testing.T) {
+	for _, input := range []string{`b"\q"`, `b"\x4"`, `b"\xzz"`} {
+		p := New(lexer.NewFromString("test", input))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			t.Errorf("[%s] expected a parser error", input)
+		}
+	}
+}
+
 func TestParsingArrayLiteral(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -564,6 +669,73 @@ func TestParsingArrayLiteral(t *testing.T) {
 	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
+func TestParsingSetLiteral(t *testing.T) {
+	input := "#{1, 2 * 2}"
+
+	p := New(lexer.NewFromString("test", input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	set, ok := stmt.Expression.(*ast.SetLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.SetLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(set.Elements) != 2 {
+		t.Fatalf("len(set.Elements) not 2, got=%d", len(set.Elements))
+	}
+	testIntegerLiteral(t, set.Elements[0], 1)
+	testInfixExpression(t, set.Elements[1], 2, "*", 2)
+}
+
+func TestParsingTupleLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"()", "()"},
+		{"(1,)", "(1,)"},
+		{"(1, 2 * 2)", "(1, (2 * 2))"},
+		{"(1, 2,)", "(1, 2)"},
+		{"(1)", "1"},
+		{"((1, 2), 3)", "((1, 2), 3)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.NewFromString("test", tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("[%s] expected=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestRecordStatement(t *testing.T) {
+	p := New(lexer.NewFromString("test", "record Point { x, y }; Point(1, 2)"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.RecordStatement)
+	if !ok {
+		t.Fatalf("statement not *ast.RecordStatement. got=%T", program.Statements[0])
+	}
+	if stmt.Name.Value != "Point" || len(stmt.Fields) != 2 || stmt.Fields[0].Value != "x" || stmt.Fields[1].Value != "y" {
+		t.Errorf("wrong record statement. got=%s", stmt)
+	}
+
+	p = New(lexer.NewFromString("test", "record Point { x, x }"))
+	p.ParseProgram()
+	if errs := p.Errors(); len(errs) == 0 || !strings.Contains(errs[0], "duplicate field x in record Point") {
+		t.Errorf("wrong errors for duplicate field. got=%v", errs)
+	}
+}
+
 func TestParsingIndexExpression(t *testing.T) {
 	input := "myArray[1 + 1];"
 	p := New(lexer.NewFromString("test", input))
@@ -614,6 +786,12 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		expectedValue := expected[literal.String()]
 		testIntegerLiteral(t, value, expectedValue)
 	}
+
+	for i, want := range []string{"one", "two", "three"} {
+		if got := hash.Keys[i].String(); got != want {
+			t.Errorf("hash.Keys[%d] wrong. want=%q, got=%q", i, want, got)
+		}
+	}
 }
 
 func TestParsingEmptyHashLiteral(t *testing.T) {
@@ -702,3 +880,44 @@ func TestFunctionLiteralWithName(t *testing.T) {
 	}
 
 }
+
+func TestYieldExpressions(t *testing.T) {
+	input := `fn() { let x = yield 1; fn() { x }; yield; }`
+	p := New(lexer.NewFromString("test", input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	outer, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+	if !outer.IsGenerator {
+		t.Errorf("function containing yield is not a generator")
+	}
+
+	let := outer.Body.Statements[0].(*ast.LetStatement)
+	yield, ok := let.Value.(*ast.YieldExpression)
+	if !ok {
+		t.Fatalf("let value not *ast.YieldExpression. got=%T", let.Value)
+	}
+	testIntegerLiteral(t, yield.Value, 1)
+
+	inner := outer.Body.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if inner.IsGenerator {
+		t.Errorf("nested function without yield is a generator")
+	}
+
+	bare := outer.Body.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.YieldExpression)
+	if bare.Value != nil {
+		t.Errorf("bare yield has a value: %s", bare.Value)
+	}
+}
+
+func TestYieldOutsideFunction(t *testing.T) {
+	p := New(lexer.NewFromString("test", "yield 1;"))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || !strings.Contains(p.Errors()[0], "yield outside of a function") {
+		t.Errorf("expected a yield error, got=%v", p.Errors())
+	}
+}
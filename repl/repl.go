@@ -2,6 +2,7 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"monkey/compiler"
@@ -14,10 +15,17 @@ import (
 const PROMPT = ">"
 
 func Start(in io.Reader, out io.Writer) {
+	StartWithPolicy(in, out, nil)
+}
+
+// StartWithPolicy runs a REPL whose programs may only use what policy allows.
+func StartWithPolicy(in io.Reader, out io.Writer, policy *object.Policy) {
 	scanner := bufio.NewScanner(in)
 	constants := []object.Object{}
 	globals := make([]object.Object, vm.GlobalSize)
 	symbolTable := compiler.NewSymbolTable()
+	compiler.DefineBuiltins(symbolTable, nil)
+	ctx := object.WithPolicy(context.Background(), policy)
 
 	for {
 		fmt.Fprintf(out, PROMPT)
@@ -36,6 +44,7 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		comp := compiler.NewWithState(symbolTable, constants)
+		comp.SetPolicy(policy)
 		err := comp.Compile(program)
 		if err != nil {
 			fmt.Fprintf(out, "Compiliation failed:\n\t%+v\n", err)
@@ -43,7 +52,7 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		machine := vm.NewWithGlobalStore(comp.Bytecode(), globals)
-		err = machine.Run()
+		err = machine.RunContext(ctx)
 		if err != nil {
 			fmt.Fprintf(out, "Bytecode execution failed:\n\t%+v\n", err)
 			continue
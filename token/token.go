@@ -13,9 +13,11 @@ const (
 	EOF     = "EOF"
 
 	// Identifiers
-	IDENT = "IDENT"
-	INT   = "INT"
-	FLOAT = "FLOAT"
+	IDENT   = "IDENT"
+	INT     = "INT"
+	FLOAT   = "FLOAT"
+	DECIMAL = "DECIMAL"
+	BYTES   = "BYTES"
 
 	// Operators
 	ASSIGN   = "="
@@ -23,11 +25,16 @@ const (
 	MINUS    = "-"
 	BANG     = "!"
 	ASTERISK = "*"
+	POWER    = "**"
 	SLASH    = "/"
 	LT       = "<"
 	GT       = ">"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	PIPE     = "|>"
+	COMPOSE  = ">>"
+	QUESTION = "?"
+	COALESCE = "??"
 
 	// Delimiters
 	COMMA     = ","
@@ -36,6 +43,7 @@ const (
 	RPAREN    = ")"
 	LBRACE    = "{"
 	RBRACE    = "}"
+	SET_BRACE = "#{"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
@@ -45,12 +53,23 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	YIELD    = "YIELD"
+	SPAWN    = "SPAWN"
+	RECORD   = "RECORD"
 
 	// Built-ins
 	STRING   = "STRING"
 	LBRACKET = "["
 	RBRACKET = "]"
 	COLON    = ":"
+	DOT      = "."
+
+	// Ranges
+	RANGE           = ".."
+	RANGE_INCLUSIVE = "..="
+
+	// Optional chaining; a?[k] is lexed as ? and [, and told apart from c ? [x] : y by the parser
+	OPTIONAL_DOT = "?."
 
 	// Comment
 	COMMENT = "#"
@@ -64,6 +83,9 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"yield":  YIELD,
+	"spawn":  SPAWN,
+	"record": RECORD,
 }
 
 func LookupIdent(ident string) TokenType {
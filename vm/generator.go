@@ -0,0 +1,50 @@
+package vm
+
+import (
+	"monkey/object"
+)
+
+// coroutine runs a generator on a child VM.  The child shares its constants and globals with
+// the VM that called the generator function, but has its own stack and frames, so its
+// frame can stay suspended at a yield between calls to Resume.
+type coroutine struct {
+	vm      *VM
+	started bool
+}
+
+func (vm *VM) newGenerator(cl *object.Closure, args []object.Object) *object.Generator {
+	// the generator body runs on behalf of the task that called the generator function
+	child := vm.newChildVM(vm.ctx)
+
+	// lay the stack out the way OpCall does: the closure followed by its arguments
+	child.growStack(1 + max(len(args), cl.Fn.NumLocals))
+	child.stack[0] = cl
+	copy(child.stack[1:], args)
+	frame := NewFrame(cl, 1)
+	child.pushFrame(frame)
+	child.sp = frame.basePointer + cl.Fn.NumLocals
+
+	return object.NewGenerator(&coroutine{vm: child})
+}
+
+func (co *coroutine) Resume(sent object.Object) (object.Object, bool) {
+	if co.started {
+		// the value sent in becomes the result of the yield expression
+		err := co.vm.push(sent)
+		if err != nil {
+			return errorObject(err), true
+		}
+	}
+	co.started = true
+
+	err := co.vm.run()
+	if err != nil {
+		return errorObject(err), true
+	}
+
+	if co.vm.suspended {
+		co.vm.suspended = false
+		return co.vm.pop(), false
+	}
+	return Null, true
+}
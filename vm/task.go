@@ -0,0 +1,87 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"monkey/object"
+)
+
+// executeSpawn starts the callee below numArgs arguments on the stack in a new task, and
+// replaces them with the task.  The task runs on a child VM on its own goroutine.
+func (vm *VM) executeSpawn(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee.(type) {
+	case *object.Closure, *object.Builtin, *object.Composition:
+	default:
+		return fmt.Errorf("spawn of non-function %s", callee.Type())
+	}
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+	vm.sp = vm.sp - numArgs - 1
+
+	task := vm.shared.sched.NewTask("")
+	child := vm.newChildVM(object.WithTask(vm.ctx, task))
+	vm.shared.spawned.Store(true)
+
+	task.Start()
+	go func() {
+		task.Finish(child.callFunction(callee, args))
+	}()
+	return vm.push(task)
+}
+
+// callFunction calls fn with args on an otherwise idle child VM and returns its result.
+func (vm *VM) callFunction(fn object.Object, args []object.Object) object.Object {
+	result, err := vm.call(fn, args)
+	if err != nil {
+		return errorObject(err)
+	}
+	return result
+}
+
+// CallFunction calls fn with args on vm while it runs a builtin, for object.CallFunction.
+// An error that stops fn is returned with a Cause, so that it stops vm too.
+func (vm *VM) CallFunction(ctx context.Context, fn object.Object, args ...object.Object) object.Object {
+	base, depth := vm.sp, vm.framesIndex
+	result, err := vm.call(fn, args)
+	if err == nil {
+		return result
+	}
+	errObj, ok := err.(*object.Error)
+	if !ok {
+		errObj = &object.Error{Message: err.Error(), Cause: err}
+		vm.withStack(errObj)
+	}
+	// unwind the frames fn left behind, back to the builtin's caller
+	vm.sp, vm.framesIndex = base, depth
+	return errObj
+}
+
+// call pushes fn and args above the values on vm's stack and runs vm until fn returns.  If fn
+// fails, its frames are left on vm for the stack trace.
+func (vm *VM) call(fn object.Object, args []object.Object) (object.Object, error) {
+	base, depth := vm.sp, vm.framesIndex
+	if base+1+len(args) >= vm.stackSize {
+		return nil, vm.stackOverflow()
+	}
+	vm.growStack(base + 1 + len(args))
+	vm.stack[base] = fn
+	copy(vm.stack[base+1:], args)
+	vm.sp = base + 1 + len(args)
+
+	err := vm.executeCall(len(args))
+	if err == nil {
+		err = vm.runUntil(depth)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := object.Object(Null)
+	if vm.sp > base {
+		// void builtins push nothing
+		result = vm.pop()
+	}
+	vm.sp = base
+	return result, nil
+}
@@ -1,27 +1,62 @@
 package vm
 
 import (
+	"context"
 	"fmt"
 	"monkey/code"
 	"monkey/compiler"
 	"monkey/object"
+	"slices"
+	"sync"
+	"sync/atomic"
 )
 
 const StackSize = 2048
 const GlobalSize = 65536
 const MaxFrames = 1024
 
+// A child VM starts with room for childStackSize values and childFrames frames, and grows
+// toward its stackSize and maxFrames only as deep as it runs, so generators and tasks are
+// cheap to make.
+const (
+	childStackSize = 32
+	childFrames    = 4
+)
+
+// checkInterval is how many instructions run between checks for cancellation.
+const checkInterval = 1024
+
 var True = &object.Boolean{Value: true}
 var False = &object.Boolean{Value: false}
 var Null = &object.Null{}
 
+// shared is the state shared by the main VM of a program and the child VMs running its
+// generators and tasks.  Globals are shared by every task of the program.  Until the first
+// spawn there is only one task, so globalsMu is only taken once spawned is set.
+type shared struct {
+	sched     *object.Scheduler
+	globalsMu sync.RWMutex
+	spawned   atomic.Bool
+}
+
 type VM struct {
 	constants   []object.Object
 	stack       []object.Object
 	sp          int // always points to the next value.  Stack top is stack[sp-1]
 	globals     []object.Object
+	shared      *shared
 	frames      []*Frame
 	framesIndex int
+	suspended   bool // a generator yielded and the value it yielded is on top of the stack
+	ctx         context.Context
+	task        *object.Task // the main task, started and finished by Run; nil for child VMs
+	ticks       int          // instructions executed, to check ctx every checkInterval of them
+	budget      *object.Budget
+	policy      *object.Policy
+	builtins    []string          // the bytecode's builtin names
+	resolved    []*object.Builtin // the builtins called those names, nil if undefined
+	stackSize   int               // StackSize, or less when limited
+	maxFrames   int               // MaxFrames, or less when limited
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
@@ -32,13 +67,21 @@ func New(bytecode *compiler.Bytecode) *VM {
 	frames := make([]*Frame, MaxFrames)
 	frames[0] = mainFrame
 
+	r := &shared{sched: object.NewScheduler()}
+	task := r.sched.NewTask("main")
 	return &VM{
 		constants:   bytecode.Constants,
 		stack:       make([]object.Object, StackSize),
 		sp:          0,
 		globals:     make([]object.Object, GlobalSize),
+		shared:      r,
 		frames:      frames,
 		framesIndex: 1,
+		ctx:         object.WithTask(context.Background(), task),
+		task:        task,
+		builtins:    bytecode.Builtins,
+		stackSize:   StackSize,
+		maxFrames:   MaxFrames,
 	}
 }
 
@@ -48,6 +91,31 @@ func NewWithGlobalStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
 	return vm
 }
 
+// newChildVM returns a VM sharing vm's constants and globals, with its own stack and frames.
+// Its frames[0] runs no instructions, so run returns once the frames pushed onto it return.
+func (vm *VM) newChildVM(ctx context.Context) *VM {
+	mainFn := &object.CompiledFunction{Instructions: []byte{}}
+	frames := make([]*Frame, childFrames)
+	frames[0] = NewFrame(&object.Closure{Fn: mainFn}, 0)
+
+	child := &VM{
+		constants:   vm.constants,
+		stack:       make([]object.Object, childStackSize),
+		globals:     vm.globals,
+		shared:      vm.shared,
+		frames:      frames,
+		framesIndex: 1,
+		budget:      vm.budget,
+		policy:      vm.policy,
+		builtins:    vm.builtins,
+		resolved:    vm.resolved,
+		stackSize:   vm.stackSize,
+		maxFrames:   vm.maxFrames,
+	}
+	child.ctx = object.WithCaller(ctx, child)
+	return child
+}
+
 func (vm *VM) StackTop() object.Object {
 	if vm.sp == 0 {
 		return nil
@@ -56,12 +124,71 @@ func (vm *VM) StackTop() object.Object {
 }
 
 func (vm *VM) Run() error {
+	return vm.RunContext(context.Background())
+}
+
+// RunContext runs the program until it ends or ctx is done.  If ctx is canceled or its
+// deadline passes, it returns an *object.Error whose cause is object.ErrCanceled or
+// object.ErrDeadline.  Tasks spawned by the program are stopped along with it.
+//
+// The program is also held to the limits from object.WithLimits, failing with an
+// *object.LimitExceeded cause, and the policy from object.WithPolicy, if ctx has them.
+// Builtins are looked up by name in the registry from object.WithRegistry.
+func (vm *VM) RunContext(ctx context.Context) error {
+	vm.start(ctx)
+	defer vm.task.Finish(nil)
+	return vm.run()
+}
+
+// RunFunction calls fn, a function of the program, with args in place of running the
+// program, and returns its result.  It runs under ctx the way RunContext does.
+func (vm *VM) RunFunction(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	vm.start(ctx)
+	defer vm.task.Finish(nil)
+	return vm.call(fn, args)
+}
+
+// start readies vm to run under ctx, and starts its main task.
+func (vm *VM) start(ctx context.Context) {
+	vm.ctx = object.WithCaller(object.WithTask(ctx, vm.task), vm)
+	vm.budget = object.BudgetFromContext(ctx)
+	vm.policy = object.PolicyFromContext(ctx)
+	registry := object.RegistryFromContext(ctx)
+	vm.resolved = make([]*object.Builtin, len(vm.builtins))
+	for i, name := range vm.builtins {
+		vm.resolved[i], _ = registry.Lookup(name)
+	}
+	limits := vm.budget.Limits()
+	if limits.MaxStackSize > 0 && limits.MaxStackSize < StackSize {
+		vm.stackSize = limits.MaxStackSize
+	}
+	if limits.MaxCallDepth > 0 && limits.MaxCallDepth < MaxFrames {
+		// frames[0] is the main program, not a call
+		vm.maxFrames = limits.MaxCallDepth + 1
+	}
+	vm.task.Start()
+}
+
+func (vm *VM) run() error {
+	return vm.runUntil(0)
+}
+
+// runUntil runs vm until the frame at depth returns, or frames[0] ends.
+func (vm *VM) runUntil(depth int) error {
 	var err error
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
 
-	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+	for vm.framesIndex > depth && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.ticks++
+		if vm.ticks%checkInterval == 0 && vm.ctx.Err() != nil {
+			return vm.withStack(object.NewInterruptError(vm.ctx))
+		}
+		if errObj := vm.budget.Step(); errObj != nil {
+			return vm.withStack(errObj)
+		}
+
 		vm.currentFrame().ip++
 		ip = vm.currentFrame().ip
 		ins = vm.currentFrame().Instructions()
@@ -72,13 +199,13 @@ func (vm *VM) Run() error {
 			constIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
 			err = vm.push(vm.constants[constIndex])
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpPow:
 			err = vm.executeBinaryOperation(op)
 		case code.OpTrue:
 			err = vm.push(True)
 		case code.OpFalse:
 			err = vm.push(False)
-		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan:
+		case code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
 			err = vm.executeComparison(op)
 		case code.OpBang:
 			err = vm.executeBangOperator()
@@ -96,6 +223,18 @@ func (vm *VM) Run() error {
 			if !isTruthy(condition) {
 				vm.currentFrame().ip = pos - 1
 			}
+		case code.OpJumpNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			if isNull(vm.StackTop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpNotNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			if !isNull(vm.StackTop()) {
+				vm.currentFrame().ip = pos - 1
+			}
 		case code.OpNull:
 			err = vm.push(Null)
 		case code.OpGetLocal:
@@ -111,17 +250,17 @@ func (vm *VM) Run() error {
 		case code.OpSetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
-			vm.globals[globalIndex] = vm.pop()
+			vm.setGlobal(globalIndex, vm.pop())
 		case code.OpGetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
-			err = vm.push(vm.globals[globalIndex])
+			err = vm.push(vm.getGlobal(globalIndex))
 		case code.OpArray:
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
 			array := vm.buildArray(vm.sp-numElements, vm.sp)
 			vm.sp = vm.sp - numElements
-			err = vm.push(array)
+			err = vm.pushAllocated(array)
 		case code.OpHash:
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.currentFrame().ip += 2
@@ -130,11 +269,48 @@ func (vm *VM) Run() error {
 				return err
 			}
 			vm.sp = vm.sp - numElements
-			err = vm.push(hash)
+			err = vm.pushAllocated(hash)
+		case code.OpTuple:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp = vm.sp - numElements
+			err = vm.pushAllocated(&object.Tuple{Elements: elements})
+		case code.OpSet:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			var set object.Object
+			set, err = vm.buildSet(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+			err = vm.pushAllocated(set)
 		case code.OpIndex:
 			index := vm.pop()
 			left := vm.pop()
 			err = vm.executeIndexExpression(left, index)
+		case code.OpSlice:
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+			err = vm.executeSliceExpression(left, start, end)
+		case code.OpRange:
+			inclusive := code.ReadUint8(ins[ip+1:]) == 1
+			vm.currentFrame().ip += 1
+			end := vm.pop()
+			start := vm.pop()
+			err = vm.executeRangeExpression(start, end, inclusive)
+		case code.OpCompose:
+			second := vm.pop()
+			first := vm.pop()
+			composed, errObj := object.Compose(first, second)
+			if errObj != nil {
+				err = errObj
+			} else {
+				err = vm.push(composed)
+			}
 		case code.OpReturnValue:
 			returnValue := vm.pop()
 			frame := vm.popFrame()
@@ -151,8 +327,7 @@ func (vm *VM) Run() error {
 		case code.OpGetBuiltin:
 			index := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip += 1
-			def := object.Builtins[index]
-			err = vm.push(def.Builtin)
+			err = vm.pushBuiltin(int(index))
 		case code.OpClosure:
 			constIndex := code.ReadUint16(ins[ip+1:])
 			numFree := code.ReadUint8(ins[ip+3:])
@@ -166,6 +341,13 @@ func (vm *VM) Run() error {
 		case code.OpCurrentClosure:
 			currentClosure := vm.currentFrame().cl
 			err = vm.push(currentClosure)
+		case code.OpYield:
+			vm.suspended = true
+			return nil
+		case code.OpSpawn:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.executeSpawn(int(numArgs))
 		}
 
 		if err != nil {
@@ -175,6 +357,49 @@ func (vm *VM) Run() error {
 	return nil
 }
 
+// withStack adds the function of every frame to the stack trace of an error that stops the VM.
+func (vm *VM) withStack(err *object.Error) error {
+	for i := vm.framesIndex - 1; i >= 0; i-- {
+		name := vm.frames[i].cl.Fn.Name
+		switch {
+		case i == 0 && vm.task == nil:
+			// the empty base frame of a child VM
+			continue
+		case i == 0:
+			name = "<main>"
+		case name == "":
+			name = "<anonymous>"
+		}
+		err.AddFrame(name)
+	}
+	return err
+}
+
+// errorObject turns an error from running a child VM into a Monkey error value, keeping
+// the cause of an interrupt.
+func errorObject(err error) *object.Error {
+	if errObj, ok := err.(*object.Error); ok {
+		return errObj
+	}
+	return &object.Error{Message: err.Error()}
+}
+
+func (vm *VM) getGlobal(index uint16) object.Object {
+	if vm.shared.spawned.Load() {
+		vm.shared.globalsMu.RLock()
+		defer vm.shared.globalsMu.RUnlock()
+	}
+	return vm.globals[index]
+}
+
+func (vm *VM) setGlobal(index uint16, value object.Object) {
+	if vm.shared.spawned.Load() {
+		vm.shared.globalsMu.Lock()
+		defer vm.shared.globalsMu.Unlock()
+	}
+	vm.globals[index] = value
+}
+
 func isTruthy(obj object.Object) bool {
 	switch o := obj.(type) {
 	case *object.Boolean:
@@ -186,15 +411,55 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+func isNull(obj object.Object) bool {
+	return obj.Type() == object.NULL_OBJ
+}
+
 func (vm *VM) push(o object.Object) error {
-	if vm.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+	if vm.sp >= vm.stackSize {
+		return vm.stackOverflow()
+	}
+	if vm.sp >= len(vm.stack) {
+		vm.growStack(vm.sp + 1)
 	}
 	vm.stack[vm.sp] = o
 	vm.sp++
 	return nil
 }
 
+// pushAllocated pushes a newly created string, array or hash, charging it to the budget.
+func (vm *VM) pushAllocated(o object.Object) error {
+	if errObj := vm.budget.Allocate(o); errObj != nil {
+		return vm.withStack(errObj)
+	}
+	return vm.push(o)
+}
+
+// growStack makes room for at least n values on the stack of a child VM, which starts small.
+// Callers check n against stackSize first.
+func (vm *VM) growStack(n int) {
+	if n <= len(vm.stack) {
+		return
+	}
+	stack := make([]object.Object, max(n, min(2*len(vm.stack), vm.stackSize)))
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+func (vm *VM) stackOverflow() error {
+	if vm.stackSize < StackSize {
+		return vm.withStack(object.NewLimitError("MaxStackSize", int64(vm.stackSize)))
+	}
+	return fmt.Errorf("stack overflow")
+}
+
+func (vm *VM) frameOverflow() error {
+	if vm.maxFrames < MaxFrames {
+		return vm.withStack(object.NewLimitError("MaxCallDepth", int64(vm.maxFrames-1)))
+	}
+	return fmt.Errorf("stack overflow")
+}
+
 func (vm *VM) pop() object.Object {
 	o := vm.stack[vm.sp-1]
 	vm.sp--
@@ -206,11 +471,10 @@ func (vm *VM) LastPoppedStackElem() object.Object {
 }
 func (vm *VM) executeMinusOperator() error {
 	op := vm.pop()
-	if op.Type() != object.INTEGER_OBJ {
+	if !object.IsNumber(op) {
 		return fmt.Errorf("unsupported type for negation: %s", op.Type())
 	}
-	value := op.(*object.Integer).Value
-	return vm.push(&object.Integer{Value: -value})
+	return vm.push(object.Negate(op))
 }
 
 func (vm *VM) executeBangOperator() error {
@@ -234,72 +498,57 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	rightType := right.Type()
 
 	switch {
-	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
-		return vm.executeBinaryIntegerOperation(op, left, right)
+	case object.IsNumber(left) && object.IsNumber(right):
+		return vm.executeBinaryNumberOperation(op, left, right)
 	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
 		return vm.executeBinaryStringOperation(op, left, right)
+	case leftType == object.BYTES_OBJ && rightType == object.BYTES_OBJ:
+		return vm.executeBinaryBytesOperation(op, left, right)
 	default:
 		return fmt.Errorf("unsupported types for binary operation: %s %s", leftType, rightType)
 	}
 }
 
-func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) error {
-
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
-
-	var result int64
+// operators are the infix operators the arithmetic opcodes were compiled from.
+var operators = map[code.Opcode]string{
+	code.OpAdd: "+",
+	code.OpSub: "-",
+	code.OpMul: "*",
+	code.OpDiv: "/",
+	code.OpPow: "**",
+}
 
-	switch op {
-	case code.OpAdd:
-		result = leftValue + rightValue
-	case code.OpMul:
-		result = leftValue * rightValue
-	case code.OpDiv:
-		result = leftValue / rightValue
-	case code.OpSub:
-		result = leftValue - rightValue
-	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+func (vm *VM) executeBinaryNumberOperation(op code.Opcode, left, right object.Object) error {
+	result, err := object.Arithmetic(vm.ctx, operators[op], left, right)
+	if err != nil {
+		return err
 	}
-
-	return vm.push(&object.Integer{Value: result})
-
+	return vm.push(result)
 }
 
 func (vm *VM) executeComparison(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
-	if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
-		return vm.executeIntegerComparison(op, left, right)
-	}
 
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(right == left))
+		return vm.push(nativeBoolToBooleanObject(object.Equals(left, right)))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(right != left))
+		return vm.push(nativeBoolToBooleanObject(!object.Equals(left, right)))
+	case code.OpGreaterThan, code.OpLessThan:
+		c, err := object.Compare(left, right)
+		if err != nil {
+			return err
+		}
+		if op == code.OpLessThan {
+			c = -c
+		}
+		return vm.push(nativeBoolToBooleanObject(c > 0))
 	default:
 		return fmt.Errorf("unknown operator: %d(%s %s)", op, left.Type(), right.Type())
 	}
 }
 
-func (vm *VM) executeIntegerComparison(op code.Opcode, left, right object.Object) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
-
-	switch op {
-	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
-	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
-	case code.OpGreaterThan:
-		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
-	default:
-		return fmt.Errorf("unknown operator: %d", op)
-	}
-}
-
 func nativeBoolToBooleanObject(val bool) object.Object {
 	if val {
 		return True
@@ -313,7 +562,16 @@ func (vm *VM) executeBinaryStringOperation(op code.Opcode, left, right object.Ob
 	}
 	leftValue := left.(*object.String).Value
 	rightValue := right.(*object.String).Value
-	return vm.push(&object.String{Value: leftValue + rightValue})
+	return vm.pushAllocated(&object.String{Value: leftValue + rightValue})
+}
+
+func (vm *VM) executeBinaryBytesOperation(op code.Opcode, left, right object.Object) error {
+	if op != code.OpAdd {
+		return fmt.Errorf("unknown bytes operator: %d", op)
+	}
+	leftValue := left.(*object.Bytes).Value
+	rightValue := right.(*object.Bytes).Value
+	return vm.pushAllocated(&object.Bytes{Value: slices.Concat(leftValue, rightValue)})
 }
 
 func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
@@ -325,26 +583,37 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 }
 
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
-	hashedPairs := make(map[object.HashKey]object.HashPair)
+	hash := &object.Hash{}
 	for i := startIndex; i < endIndex; i += 2 {
-		key := vm.stack[i]
-		value := vm.stack[i+1]
-		pair := object.HashPair{Key: key, Value: value}
-		hashKey, ok := key.(object.Hashable)
-		if !ok {
-			return nil, fmt.Errorf("usable as hash key: %s", key.Type())
+		if err := hash.Set(vm.stack[i], vm.stack[i+1]); err != nil {
+			return nil, err
 		}
-		hashedPairs[hashKey.HashKey()] = pair
 	}
-	return &object.Hash{Pairs: hashedPairs}, nil
+	return hash, nil
 }
 
 func (vm *VM) executeIndexExpression(left, index object.Object) error {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return vm.executeArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeStringIndex(left, index)
+	case left.Type() == object.RANGE_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeRangeIndex(left, index)
+	case left.Type() == object.TUPLE_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeTupleIndex(left, index)
+	case left.Type() == object.BYTES_OBJ && index.Type() == object.INTEGER_OBJ:
+		return vm.executeBytesIndex(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return vm.executeHashIndex(left, index)
+	case left.Type() == object.RECORD_OBJ && index.Type() == object.STRING_OBJ:
+		value, err := left.(*object.Record).Field(index.(*object.String).Value)
+		if err != nil {
+			return err
+		}
+		return vm.push(value)
+	case index.Type() == object.STRING_OBJ:
+		return vm.executeMethodIndex(left, index)
 	default:
 		return fmt.Errorf("index operator not supported for %s", left.Type())
 	}
@@ -352,34 +621,111 @@ func (vm *VM) executeIndexExpression(left, index object.Object) error {
 
 func (vm *VM) executeArrayIndex(array, index object.Object) error {
 	arrayObject := array.(*object.Array)
-	i := index.(*object.Integer).Value
-	maxIndex := int64(len(arrayObject.Elements) - 1)
-
-	if i < 0 || i > maxIndex {
+	i, ok := object.ResolveIndex(index.(*object.Integer).Value, len(arrayObject.Elements))
+	if !ok {
 		return vm.push(Null)
 	}
 	return vm.push(arrayObject.Elements[i])
 }
 
+func (vm *VM) executeTupleIndex(tuple, index object.Object) error {
+	tupleObject := tuple.(*object.Tuple)
+	i, ok := object.ResolveIndex(index.(*object.Integer).Value, len(tupleObject.Elements))
+	if !ok {
+		return vm.push(Null)
+	}
+	return vm.push(tupleObject.Elements[i])
+}
+
+func (vm *VM) executeStringIndex(str, index object.Object) error {
+	result, ok := object.StringIndex(str.(*object.String), index.(*object.Integer).Value)
+	if !ok {
+		return vm.push(Null)
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) executeBytesIndex(b, index object.Object) error {
+	result, ok := object.BytesIndex(b.(*object.Bytes), index.(*object.Integer).Value)
+	if !ok {
+		return vm.push(Null)
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) executeRangeIndex(r, index object.Object) error {
+	result, ok := object.RangeIndex(r.(*object.Range), index.(*object.Integer).Value)
+	if !ok {
+		return vm.push(Null)
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) executeRangeExpression(start, end object.Object, inclusive bool) error {
+	if start.Type() != object.INTEGER_OBJ || end.Type() != object.INTEGER_OBJ {
+		return fmt.Errorf("range bounds must be INTEGER, got %s..%s", start.Type(), end.Type())
+	}
+	startValue := start.(*object.Integer).Value
+	endValue := end.(*object.Integer).Value
+	return vm.push(object.NewRange(startValue, endValue, inclusive))
+}
+
+func (vm *VM) executeSliceExpression(left, start, end object.Object) error {
+	result := object.Slice(left, start, end)
+	if errObj, ok := result.(*object.Error); ok {
+		return fmt.Errorf("%s", errObj.Message)
+	}
+	return vm.pushAllocated(result)
+}
+
+func (vm *VM) executeMethodIndex(left, index object.Object) error {
+	provider, ok := left.(object.MethodProvider)
+	if !ok {
+		return fmt.Errorf("index operator not supported for %s", left.Type())
+	}
+	name := index.(*object.String).Value
+	method, ok := provider.Method(name)
+	if !ok {
+		return fmt.Errorf("undefined method %s for %s", name, left.Type())
+	}
+	return vm.push(method)
+}
+
 func (vm *VM) executeHashIndex(left, index object.Object) error {
 	hashObject := left.(*object.Hash)
-	key, ok := index.(object.Hashable)
-	if !ok {
+	if _, ok := object.HashKeyOf(index); !ok {
 		return fmt.Errorf("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(index)
 	if !ok {
 		return vm.push(Null)
 	}
-	return vm.push(pair.Value)
+	return vm.push(value)
+}
+
+func (vm *VM) buildSet(startIndex, endIndex int) (object.Object, error) {
+	set := &object.Set{}
+	for i := startIndex; i < endIndex; i++ {
+		if err := set.Add(vm.stack[i]); err != nil {
+			return nil, err
+		}
+	}
+	return set, nil
 }
 
 func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.framesIndex-1]
 }
 
+// pushFrame pushes f, growing the frames of a child VM as needed.  Callers check
+// framesIndex against maxFrames first.
 func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex >= len(vm.frames) {
+		frames := make([]*Frame, min(2*len(vm.frames), vm.maxFrames))
+		copy(frames, vm.frames)
+		vm.frames = frames
+	}
 	vm.frames[vm.framesIndex] = f
 	vm.framesIndex++
 }
@@ -396,15 +742,69 @@ func (vm *VM) executeCall(numArgs int) error {
 		return vm.callClosure(calleeType, numArgs)
 	case *object.Builtin:
 		return vm.callBuiltin(calleeType, numArgs)
+	case *object.Composition:
+		return vm.callComposition(calleeType, numArgs)
+	case *object.RecordType:
+		record, err := calleeType.New(vm.stack[vm.sp-numArgs : vm.sp])
+		if err != nil {
+			return err
+		}
+		vm.sp = vm.sp - numArgs - 1
+		return vm.pushAllocated(record)
 	default:
 		return fmt.Errorf("calling non-function and non-built-tin")
 	}
 }
 
+// callComposition calls the first function of c with the arguments, and the second with
+// its result.
+func (vm *VM) callComposition(c *object.Composition, numArgs int) error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+	vm.sp = vm.sp - numArgs - 1
+
+	result, err := vm.call(c.First, args)
+	if err != nil {
+		return err
+	}
+	result, err = vm.call(c.Second, []object.Object{result})
+	if err != nil {
+		return err
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) pushBuiltin(index int) error {
+	if index >= len(vm.resolved) || vm.resolved[index] == nil {
+		if index < len(vm.builtins) {
+			return fmt.Errorf("undefined builtin %s", vm.builtins[index])
+		}
+		return fmt.Errorf("undefined builtin %d", index)
+	}
+	name, builtin := vm.builtins[index], vm.resolved[index]
+	// bytecode may have been compiled without the policy the VM runs under
+	err := vm.policy.CheckBuiltin(name, builtin)
+	if err != nil {
+		return err
+	}
+	if builtin.Value != nil {
+		return vm.push(builtin.Value)
+	}
+	return vm.push(builtin)
+}
+
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
-	result := builtin.Fn(args...)
+	result := builtin.Fn(vm.ctx, args...)
 	vm.sp = vm.sp - numArgs - 1
+	if errObj, ok := result.(*object.Error); ok && errObj.Cause != nil {
+		// interrupted builtins stop the program instead of returning an error value
+		if errObj.Stack != nil {
+			// the error stopped a function the builtin called, which traced the stack
+			return errObj
+		}
+		return vm.withStack(errObj)
+	}
 	if result != nil {
 		vm.push(result)
 	} else {
@@ -419,7 +819,20 @@ func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 	if numArgs != cl.Fn.NumParameters {
 		return fmt.Errorf("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
 	}
+	if cl.Fn.IsGenerator {
+		args := make([]object.Object, numArgs)
+		copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+		vm.sp = vm.sp - numArgs - 1
+		return vm.push(vm.newGenerator(cl, args))
+	}
+	if vm.framesIndex >= vm.maxFrames {
+		return vm.frameOverflow()
+	}
 	frame := NewFrame(cl, vm.sp-numArgs)
+	if frame.basePointer+cl.Fn.NumLocals >= vm.stackSize {
+		return vm.stackOverflow()
+	}
+	vm.growStack(frame.basePointer + cl.Fn.NumLocals)
 	vm.pushFrame(frame)
 	vm.sp = frame.basePointer + cl.Fn.NumLocals
 	return nil
@@ -1,13 +1,17 @@
 package vm
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"monkey/ast"
 	"monkey/compiler"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"strings"
 	"testing"
+	"time"
 )
 
 func parse(input string) *ast.Program {
@@ -115,13 +119,13 @@ func testExpectedObject(t *testing.T, input string, expected interface{}, actual
 			t.Errorf("[%s] object is not Hash.  got=%T (%+v)", input, actual, actual)
 			return
 		}
-		if len(hash.Pairs) != len(e) {
-			t.Errorf("Hash has wrong number of Pairs.  wat=%d, got=%d", len(e), len(hash.Pairs))
+		if hash.Len() != len(e) {
+			t.Errorf("Hash has wrong number of Pairs.  wat=%d, got=%d", len(e), hash.Len())
 		}
-		for expectedKey, expectedValue := range e {
-			pair, ok := hash.Pairs[expectedKey]
+		for _, pair := range hash.Pairs() {
+			expectedValue, ok := e[pair.Key.(object.Hashable).HashKey()]
 			if !ok {
-				t.Errorf("no pair for given key in Pairs")
+				t.Errorf("unexpected key %s in Pairs", pair.Key.Inspect())
 			}
 			err := testIntegerObject(expectedValue, pair.Value)
 			if err != nil {
@@ -300,7 +304,12 @@ func TestIndexExpressions(t *testing.T) {
 		{"[1,2,3][0+2]", 3},
 		{"[][0]", Null},
 		{"[1,2,3][99]", Null},
-		{"[1][-1]", Null},
+		{"[1][-1]", 1},
+		{"[1,2,3][-3]", 1},
+		{"[1,2,3][-4]", Null},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-1]`, "o"},
+		{`"héllo"[5]`, Null},
 		{"{1:1,2:2}[1]", 1},
 		{"{1:1,2:2}[2]", 2},
 		{"{1:1}[0]", Null},
@@ -554,3 +563,1119 @@ func TestRecursiveFunctions(t *testing.T) {
 
 	runVmTests(t, tests)
 }
+
+func TestPipeAndCompose(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3] |> rest |> rest |> len", 1},
+		{"let add = fn(a, b) { a + b }; 1 |> add(2) |> add(3)", 6},
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; 5 |> double >> inc", 11},
+		{"let double = fn(x) { x * 2 }; let inc = fn(x) { x + 1 }; let f = inc >> double; f(5)", 12},
+		{"let count = rest >> len; count([1, 2, 3])", 2},
+		{"let x = fn(x) { x }; let f = x >> x >> x; f(7)", 7},
+		{"let f = fn() { let inc = fn(x) { x + 1 }; 1 |> inc >> inc }; f()", 3},
+		{"let inc = fn(x) { x + 1 }; map([1, 2], inc >> fn(x) { x * 2 })", []int{4, 6}},
+		{"let inc = fn(x) { x + 1 }; let twice = fn(x) { x * 2 }; let f = inc >> twice; f(f(1))", 10},
+	}
+	runVmTests(t, tests)
+}
+
+func TestConditionalAndOptionalOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"true ? 1 : 2", 1},
+		{"false ? 1 : 2", 2},
+		{"1 > 2 ? 1 : 2 > 1 ? 3 : 4", 3},
+		{"first([]) ? 1 : 2", 2},
+		{"first([]) ?? 5", 5},
+		{"first([]) ?? first([]) ?? 6", 6},
+		{"3 ?? 5", 3},
+		{"false ?? 5", false},
+		{"let f = fn(x) { x ?? 10 }; f(first([])) + f(1)", 11},
+		{`let cfg = {"db": {"port": 5432}}; cfg.db.port`, 5432},
+		{`let cfg = {"db": {"port": 5432}}; cfg?.db?.port`, 5432},
+		{`let cfg = {"db": {}}; cfg.db?.missing?.port ?? 80`, 80},
+		{`let cfg = {}; cfg.db?["port"] ?? 80`, 80},
+		{`let cfg = {"ports": [1, 2]}; cfg.ports?[1]`, 2},
+		{`let lookup = fn(h) { h?.a?.b ?? 0 }; lookup({"a": {"b": 1}}) + lookup({})`, 1},
+		{`let c = true; (c ?[1] : [2])[0]`, 1},
+		{`let c = false; (c ?[1] : [2])[0]`, 2},
+		{`let a = [5]; let c = false; let v = c ? [7] : a?[0]; v`, 5},
+		{`let a = [5]; let c = true; (c ? [7] : a?[0])[0]`, 7},
+		{`let c = false; let f = fn(x) { x }; f(c ? 7 : first([])?[0] ?? 9)`, 9},
+		{`let n = first([]); n?.a.b`, Null},
+		{`let n = first([]); n?.a.b(1)[2:]`, Null},
+		{`let n = {"a": {"b": 3}}; n?.a.b`, 3},
+	}
+	runVmTests(t, tests)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1,2,3,4][1:3]", []int{2, 3}},
+		{"[1,2,3,4][:2]", []int{1, 2}},
+		{"[1,2,3,4][2:]", []int{3, 4}},
+		{"[1,2,3,4][:]", []int{1, 2, 3, 4}},
+		{"[1,2,3,4][-2:]", []int{3, 4}},
+		{"[1,2,3,4][:-1]", []int{1, 2, 3}},
+		{"[1,2,3,4][-10:10]", []int{1, 2, 3, 4}},
+		{"[1,2,3,4][3:1]", []int{}},
+		{"[1,2,3,4][5:]", []int{}},
+		{"let n = 2; [1,2,3,4][:n]", []int{1, 2}},
+		{`"héllo"[1:3]`, "él"},
+		{`"héllo"[-3:]`, "llo"},
+		{`"héllo"[10:]`, ""},
+		{`let f = fn(s) { s?[1:] ?? "none" }; f(first([])) + f("abc")`, "nonebc"},
+	}
+	runVmTests(t, tests)
+}
+
+func TestSliceErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{`{}[1:2]`, "slice operator not supported: HASH"},
+		{`[1, 2]["a":]`, "slice index must be INTEGER, got STRING"},
+		{`5[:1]`, "slice operator not supported: INTEGER"},
+		{`"abc"[:true]`, "slice index must be INTEGER, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %+v", err)
+		}
+		err = New(comp.Bytecode()).Run()
+		if err == nil {
+			t.Fatalf("[%s] expected VM error but got success", tt.input)
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("[%s] wrong VM error: want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{"1 / 0", "division by zero"},
+		{"let f = fn(x) { 10 / x }; f(0)", "division by zero"},
+		{"1 < \"a\"", "can't compare INTEGER and STRING"},
+		{"1.5 + true", "unsupported types for binary operation: FLOAT BOOLEAN"},
+		{"2 ** 64 / 0", "division by zero"},
+		{"2 ** 100000000", "result of 2 ** 100000000 is too large"},
+		{"1 >> len", "operands of >> must be functions, got INTEGER >> BUILTIN"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %+v", err)
+		}
+		err = New(comp.Bytecode()).Run()
+		if err == nil {
+			t.Fatalf("[%s] expected VM error but got success", tt.input)
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("[%s] wrong VM error: want=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []vmTestCase{
+		{"len(0..5)", 5},
+		{"len(0..=5)", 6},
+		{"len(5..0)", 0},
+		{"(0..5)[2]", 2},
+		{"(0..5)[-1]", 4},
+		{"(0..5)[5]", Null},
+		{"collect(1..=3)", []int{1, 2, 3}},
+		{"collect(3..1)", []int{}},
+		{"let n = 4; collect(0..n)", []int{0, 1, 2, 3}},
+		{"collect((0..10)[2:5])", []int{2, 3, 4}},
+		{"collect((1..=5)[1:-1])", []int{2, 3, 4}},
+		{"len(9223372036854775806..=9223372036854775807)", 2},
+		{"collect(9223372036854775806..=9223372036854775807)", []int{9223372036854775806, 9223372036854775807}},
+		{"(0..=9223372036854775807)[5]", 5},
+		{"(0..=9223372036854775807)[-1]", 9223372036854775807},
+		{"len((1..=9223372036854775807)[1:])", 9223372036854775806},
+		{"collect([1, 2])", []int{1, 2}},
+		{`len(collect("héllo"))`, 5},
+	}
+	runVmTests(t, tests)
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []vmTestCase{
+		{"let gen = fn() { yield 1; yield 2; yield 3; }; collect(gen())", []int{1, 2, 3}},
+		{"let gen = fn(a, b) { yield a; yield b; }; collect(gen(4, 5))", []int{4, 5}},
+		{"let gen = fn() { yield 1; 99 }; let g = gen(); g.next(); g.next(); g.done()", true},
+		{"let gen = fn() { yield 1; }; let g = gen(); g.next(); g.done()", false},
+		{"let gen = fn() { yield 1; }; let g = gen(); let a = g.next(); let b = g.next(); b ?? a", 1},
+		{`let gen = fn(a) { let x = yield a; let y = yield x + 1; yield y * 2; };
+		  let g = gen(1);
+		  g.next() + g.send(10) + g.send(5)`, 22},
+		{`let make = fn() { let base = 10; fn() { let one = fn() { 1 }; yield base; yield base + one() } };
+		  collect(make()())`, []int{10, 11}},
+		{`let g1 = fn() { yield 1; yield 2; }; let g2 = fn(g) { yield g.next() * 10; yield g.next() * 10; };
+		  collect(g2(g1()))`, []int{10, 20}},
+		{`let depth = fn(n) { if (n == 0) { 0 } else { 1 + depth(n - 1) } };
+		  let gen = fn(n) { yield depth(n); yield [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33][32] };
+		  collect(gen(500))`, []int{500, 33}},
+		{`let gens = map(0..1000, fn(i) { fn() { yield i }() }); reduce(gens, fn(acc, g) { acc + g.next() }, 0)`, 499500},
+		{"let gen = fn() { yield 1 + true; }; gen().next()",
+			&object.Error{Message: "unsupported types for binary operation: INTEGER BOOLEAN"}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestGeneratorMethodErrors(t *testing.T) {
+	input := "let gen = fn() { yield 1; }; gen().reset"
+	comp := compiler.New()
+	err := comp.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %+v", err)
+	}
+
+	err = New(comp.Bytecode()).Run()
+	expected := "undefined method reset for GENERATOR"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong VM error: want=%q, got=%v", expected, err)
+	}
+}
+
+func TestTasksAndChannels(t *testing.T) {
+	tests := []vmTestCase{
+		{"let ch = chan(); spawn fn() { ch.send(42) }(); ch.recv()", 42},
+		{"let t = spawn fn(a, b) { a * b }(6, 7); t.wait()", 42},
+		{"spawn len([1, 2]).wait()", 2},
+		{"let x = 1; let t = spawn fn() { x + 1 }(); t.wait()", 2},
+		{`let ch = chan();
+		  let producer = fn(n) { let loop = fn(i) { if (i < n) { ch.send(i); loop(i + 1) } else { ch.close() } }; loop(0) };
+		  spawn producer(3);
+		  let a = ch.recv(); let b = ch.recv(); let c = ch.recv();
+		  a + b + c + (ch.recv() ?? 10)`, 13},
+		{"let ch = chan(2); ch.send(1); ch.send(2); ch.recv() + ch.recv()", 3},
+		{"let a = chan(); let b = chan(1); b.send(5); select([a, b])", []int{1, 5}},
+		{"let a = chan(1); select([[a, 7]])[0] + a.recv()", 7},
+		{"let a = chan(); let b = chan(); spawn fn() { b.send(9) }(); select([a, b])", []int{1, 9}},
+		{"let a = chan(); spawn fn() { a.recv() + 1 }(); select([[a, 4]])[0]", 0},
+		{"let t = spawn fn() { 1 }(); t.wait(); t.done()", true},
+		{`let a = {"f": fn(x) { x }}; let t = spawn a?.f(1); t.wait()`, 1},
+		{"let ch = chan(); ch.close(); ch.close()", &object.Error{Message: "close of closed channel"}},
+		{"let ch = chan(1); ch.close(); ch.send(1)", &object.Error{Message: "send on closed channel"}},
+		{"chan(-1)", &object.Error{Message: "negative channel capacity: -1"}},
+		{"let ch = chan(); ch.recv()", &object.Error{Message: "deadlock: all tasks are blocked: main (recv)"}},
+		{"spawn fn() { 1 + true }().wait()",
+			&object.Error{Message: "unsupported types for binary operation: INTEGER BOOLEAN"}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestDeadlockReportsBlockedTasks(t *testing.T) {
+	input := `let a = chan(); let b = chan();
+	          let t = spawn fn() { a.recv() }();
+	          spawn fn() { b.send(1) }();
+	          t.wait()`
+	comp := compiler.New()
+	err := comp.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %+v", err)
+	}
+
+	vm := New(comp.Bytecode())
+	err = vm.Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	errObj, ok := vm.LastPoppedStackElem().(*object.Error)
+	if !ok {
+		t.Fatalf("object is not Error. got=%T (%+v)", vm.LastPoppedStackElem(), vm.LastPoppedStackElem())
+	}
+	for _, want := range []string{"deadlock: all tasks are blocked: main (wait task ", "(recv)", "(send)"} {
+		if !strings.Contains(errObj.Message, want) {
+			t.Errorf("deadlock error %q does not contain %q", errObj.Message, want)
+		}
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	fib := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };"
+	slow := fib + "fib(40)"
+	tests := []struct {
+		input   string
+		timeout time.Duration // zero for a context canceled before the run
+		cause   error
+		stack   []string
+	}{
+		{slow, 0, object.ErrCanceled, nil},
+		{slow, 20 * time.Millisecond, object.ErrDeadline, nil},
+		{"let f = fn() { exec(\"sleep 5\") }; f()", 20 * time.Millisecond, object.ErrDeadline, []string{"f", "<main>"}},
+		{fib + "let ch = chan(); let f = fn() { spawn fib(40); ch.recv() }; f()", 20 * time.Millisecond,
+			object.ErrDeadline, []string{"f", "<main>"}},
+		{"all(0..9223372036854775807)", 20 * time.Millisecond, object.ErrDeadline, nil},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %+v", err)
+		}
+
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if tt.timeout == 0 {
+			ctx, cancel = context.WithCancel(context.Background())
+			cancel()
+		} else {
+			ctx, cancel = context.WithTimeout(context.Background(), tt.timeout)
+		}
+		err = New(comp.Bytecode()).RunContext(ctx)
+		cancel()
+		if !errors.Is(err, tt.cause) {
+			t.Errorf("[%s]: wrong error. want=%v, got=%v", tt.input, tt.cause, err)
+			continue
+		}
+		stack := err.(*object.Error).Stack
+		if len(stack) == 0 || stack[len(stack)-1] != "<main>" {
+			t.Errorf("[%s]: stack trace does not end in <main>: %v", tt.input, stack)
+		}
+		if tt.stack != nil && strings.Join(stack, ",") != strings.Join(tt.stack, ",") {
+			t.Errorf("[%s]: wrong stack trace. want=%v, got=%v", tt.input, tt.stack, stack)
+		}
+	}
+}
+
+func TestDeadlineWhileBlocked(t *testing.T) {
+	// a deadline that stops the other tasks mustn't be reported as the deadlock they leave
+	tests := []struct {
+		input   string
+		timeout time.Duration
+		runs    int
+	}{
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+		  let ch = chan(); spawn fib(40); ch.recv()`, 20 * time.Millisecond, 5},
+		{"let ch = chan(); ch.recv()", -time.Second, 50},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %+v", err)
+		}
+		for i := 0; i < tt.runs; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			err = New(comp.Bytecode()).RunContext(ctx)
+			cancel()
+			if !errors.Is(err, object.ErrDeadline) {
+				t.Fatalf("[%s]: wrong error. want=%v, got=%v", tt.input, object.ErrDeadline, err)
+			}
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	fib := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(25)"
+	count := "let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)"
+	build := "let f = fn(n) { if (n == 0) { [] } else { push(f(n - 1), n) } }; f(100)"
+	double := `let s = fn(x, n) { if (n == 0) { x } else { s(x + x, n - 1) } }; s("ab", 20)`
+
+	tests := []struct {
+		input  string
+		limits object.Limits
+		limit  string
+	}{
+		{fib, object.Limits{MaxInstructions: 1000}, "MaxInstructions"},
+		{count, object.Limits{MaxCallDepth: 50}, "MaxCallDepth"},
+		{count, object.Limits{MaxCallDepth: 101}, ""},
+		{count, object.Limits{MaxStackSize: 64}, "MaxStackSize"},
+		{build, object.Limits{MaxAllocations: 10}, "MaxAllocations"},
+		{double, object.Limits{MaxAllocatedBytes: 1000}, "MaxAllocatedBytes"},
+		{"collect(0..100000000)", object.Limits{MaxAllocatedBytes: 1000}, "MaxAllocatedBytes"},
+		{`puts("hello")`, object.Limits{MaxOutputBytes: 3}, "MaxOutputBytes"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %+v", err)
+		}
+
+		err = New(comp.Bytecode()).RunContext(object.WithLimits(context.Background(), tt.limits))
+		if tt.limit == "" {
+			if err != nil {
+				t.Errorf("[%s]: unexpected error: %s", tt.input, err)
+			}
+			continue
+		}
+		var exceeded *object.LimitExceeded
+		if !errors.As(err, &exceeded) {
+			t.Errorf("[%s]: expected a LimitExceeded error, got=%v", tt.input, err)
+			continue
+		}
+		if exceeded.Limit != tt.limit {
+			t.Errorf("[%s]: wrong limit exceeded. want=%s, got=%s", tt.input, tt.limit, exceeded.Limit)
+		}
+	}
+}
+
+func TestStackTraceIsBounded(t *testing.T) {
+	tests := []struct {
+		input string
+		first string
+	}{
+		{"let f = fn(n) { f(n + 1) }; f(0)", "f (×"},
+		{"let g = fn(h, n) { h(n + 1) }; let f = fn(n) { g(f, n) }; f(0)", ""},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %+v", err)
+		}
+
+		err = New(comp.Bytecode()).RunContext(object.WithLimits(context.Background(), object.Limits{MaxCallDepth: 400}))
+		var errObj *object.Error
+		var exceeded *object.LimitExceeded
+		if !errors.As(err, &errObj) || !errors.As(err, &exceeded) {
+			t.Fatalf("[%s]: expected a MaxCallDepth error, got=%v", tt.input, err)
+		}
+		stack := errObj.Stack
+		if len(stack) > 32 || len(err.Error()) > 1000 {
+			t.Errorf("[%s]: stack trace too long: %d entries, %d bytes", tt.input, len(stack), len(err.Error()))
+		}
+		if !strings.HasPrefix(stack[0], tt.first) || stack[len(stack)-1] != "<main>" {
+			t.Errorf("[%s]: wrong stack trace: %v", tt.input, stack)
+		}
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	comp := compiler.New()
+	err := comp.Compile(parse("let f = fn() { f() }; f()"))
+	if err != nil {
+		t.Fatalf("compiler error: %+v", err)
+	}
+
+	err = New(comp.Bytecode()).Run()
+	if err == nil || err.Error() != "stack overflow" {
+		t.Errorf("wrong VM error: want=%q, got=%v", "stack overflow", err)
+	}
+}
+
+func TestPolicyDeniesBuiltinsAtRuntime(t *testing.T) {
+	comp := compiler.New()
+	err := comp.Compile(parse(`len("ls") + len(exec("ls"))`))
+	if err != nil {
+		t.Fatalf("compiler error: %+v", err)
+	}
+
+	ctx := object.WithPolicy(context.Background(), object.SandboxPolicy())
+	err = New(comp.Bytecode()).RunContext(ctx)
+	if !errors.Is(err, object.ErrDenied) {
+		t.Errorf("expected a policy error, got=%v", err)
+	}
+}
+
+func TestBuiltinsResolvedByName(t *testing.T) {
+	compiled := object.NewRegistry()
+	compiled.Register("double", func(n int) int { return n * 2 })
+	comp := compiler.NewWithRegistry(compiled)
+	err := comp.Compile(parse(`double(len("abc"))`))
+	if err != nil {
+		t.Fatalf("compiler error: %+v", err)
+	}
+
+	running := object.NewRegistry()
+	running.Register("triple", func(n int) int { return n * 3 })
+	running.Register("double", func(n int) int { return n + n })
+	vm := New(comp.Bytecode())
+	err = vm.RunContext(object.WithRegistry(context.Background(), running))
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, "double", 6, vm.LastPoppedStackElem())
+
+	err = New(comp.Bytecode()).Run()
+	if err == nil || err.Error() != "undefined builtin double" {
+		t.Errorf("expected an undefined builtin error, got=%v", err)
+	}
+}
+
+func TestCallFunctionFromBuiltins(t *testing.T) {
+	registry := object.NewRegistry()
+	registry.Define("apply", &object.Builtin{Fn: func(ctx context.Context, args ...object.Object) object.Object {
+		return object.CallFunction(ctx, args[0], args[1:]...)
+	}})
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`apply(fn(x) { x * 10 }, 4)`, 40},
+		{`let y = 1; apply(fn(x) { apply(fn(z) { z + x + y }, 2) }, 3) + 1`, 7},
+		{`apply(len, "four")`, 4},
+		{`let f = fn() { }; apply(f)`, Null},
+		{`let gen = fn() { yield apply(fn() { 5 }) }; gen().next()`, 5},
+		{`spawn apply(fn(x) { x }, 8).wait()`, 8},
+		{`apply(fn(x) { x }, 1, 2)`, "wrong number of arguments: want=1, got=2\n\tat <main>"},
+		{`let f = fn() { true + 1 }; apply(fn() { f() })`, "unsupported types for binary operation: BOOLEAN INTEGER\n\tat f\n\tat <anonymous>\n\tat <main>"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.NewWithRegistry(registry)
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.RunContext(object.WithRegistry(context.Background(), registry))
+		if msg, ok := tt.expected.(string); ok {
+			if err == nil || err.Error() != msg {
+				t.Errorf("[%s] wrong error. want=%q, got=%v", tt.input, msg, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[%s] vm error: %s", tt.input, err)
+		}
+		testExpectedObject(t, tt.input, tt.expected, vm.LastPoppedStackElem())
+	}
+}
+
+func TestRunFunction(t *testing.T) {
+	comp := compiler.New()
+	err := comp.Compile(parse(`let add = fn(a, b) { a + b };`))
+	if err != nil {
+		t.Fatalf("compiler error: %+v", err)
+	}
+	globals := make([]object.Object, GlobalSize)
+	err = NewWithGlobalStore(comp.Bytecode(), globals).Run()
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	bytecode := &compiler.Bytecode{Constants: comp.Bytecode().Constants}
+	result, err := NewWithGlobalStore(bytecode, globals).RunFunction(context.Background(), globals[0],
+		&object.Integer{Value: 2}, &object.Integer{Value: 3})
+	if err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, "add(2, 3)", 5, result)
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`map(0..3, fn(x) { x + 1 })`, "[1, 2, 3]"},
+		{`map([], fn(x) { x })`, "[]"},
+		{`let g = fn() { yield 1; 1 / 0; yield 3 }; collect(g())`, "ERROR: division by zero"},
+		{`let g = fn() { yield 1; 1 / 0; yield 3 }; filter(g(), fn(x) { true })`, "ERROR: division by zero"},
+		{`let g = fn() { yield 1; 1 / 0; yield 3 }; reduce(g(), fn(a, b) { a + b })`, "ERROR: division by zero"},
+		{`let g = fn() { yield 1; 1 / 0; yield 3 }; any(g(), fn(x) { x == 3 })`, "ERROR: division by zero"},
+		{`let g = fn() { yield 1; 1 / 0; yield 3 }; zip([1, 2, 3], g())`, "ERROR: division by zero"},
+		{`let ch = chan(1); let g = fn() { yield 1; yield collect(ch.recv()) }; let gen = g(); ch.send(gen); collect(gen)`, "ERROR: generator already running"},
+		{`len(map(0..5000, fn(x) { x }))`, "5000"},
+		{`filter(0..10, fn(x) { x - x / 2 * 2 == 0 })`, "[0, 2, 4, 6, 8]"},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, "10"},
+		{`reduce([1, 2, 3], fn(acc, x) { push(acc, x * x) }, [])`, "[1, 4, 9]"},
+		{`reduce([], fn(acc, x) { acc + x })`, "ERROR: reduce of empty ARRAY with no initial value"},
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort(["b", "c", "a"])`, "[a, b, c]"},
+		{`sort([3, 1, 2], fn(a, b) { b - a })`, "[3, 2, 1]"},
+		{`sort([[2, "a"], [1, "b"], [2, "c"]], fn(a, b) { a[0] - b[0] })`, "[[1, b], [2, a], [2, c]]"},
+		{`sort([1, "a"])`, "ERROR: 'sort' can't order STRING and INTEGER without a comparator"},
+		{`sort([1, 2], fn(a, b) { true })`, "ERROR: comparator passed to 'sort' must return INTEGER, got BOOLEAN"},
+		{`find([1, 2, 3], fn(x) { x > 1 })`, "2"},
+		{`find([1, 2, 3], fn(x) { x > 5 })`, "null"},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, "true"},
+		{`any([false, first([])])`, "false"},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, "true"},
+		{`all([1, false])`, "false"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`any(0..100000000000, fn(x) { x > 2 })`, "true"},
+		{`find(0..9223372036854775807, fn(x) { x > 2 })`, "3"},
+		{`all(-9223372036854775807 - 1..=9223372036854775807, fn(x) { x > 0 })`, "false"},
+		{`zip(["a", "b"], 0..9223372036854775807)`, "[[a, 0], [b, 1]]"},
+		{`let g = fn() { yield 1; yield 2; yield 3 }; find(g(), fn(x) { x > 1 })`, "2"},
+		{`let g = fn() { yield 1; yield 2 }; reduce(g(), fn(acc, x) { acc + x })`, "3"},
+		{`enumerate(3..=4)`, "[[0, 3], [1, 4]]"},
+		{`zip(1, [1])`, "ERROR: argument to 'zip' must be iterable, got INTEGER"},
+		{`enumerate(["a", "b"])`, "[[0, a], [1, b]]"},
+		{`flatten([1, [2, [3, [4]]]])`, "[1, 2, [3, [4]]]"},
+		{`flatten([1, [2, [3, [4]]]], 5)`, "[1, 2, 3, 4]"},
+		{`unique([1, 2, 1, "a", "a", true])`, "[1, 2, a, true]"},
+		{`unique([[1]])`, "ERROR: unusable as hash key: ARRAY"},
+		{`map([1], 1)`, "ERROR: argument to 'map' must be a function, got INTEGER"},
+		{`map(1, fn(x) { x })`, "ERROR: argument to 'map' must be iterable, got INTEGER"},
+		{`map([1, 2], len)`, "ERROR: argument to 'len' not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("[%s] vm error: %s", tt.input, err)
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("héllo")`, "5"},
+		{`strings.split("a,b,,c", ",")`, "[a, b, , c]"},
+		{`strings.join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`strings.join(["a", 1], "-")`, "ERROR: elements of the array passed to 'join' must be STRING, got INTEGER"},
+		{`strings.trim("  hi  ")`, "hi"},
+		{`strings.trim("xxhixx", "x")`, "hi"},
+		{`strings.trim_left("  hi ") + "|"`, "hi |"},
+		{`strings.trim_right("  hi ") + "|"`, "  hi|"},
+		{`strings.upper("héllo")`, "HÉLLO"},
+		{`strings.lower("ÀB")`, "àb"},
+		{`strings.contains("monkey", "key")`, "true"},
+		{`strings.starts_with("monkey", "mon")`, "true"},
+		{`strings.ends_with("monkey", "mon")`, "false"},
+		{`strings.index_of("héllo", "l")`, "2"},
+		{`strings.index_of("hello", "z")`, "-1"},
+		{`strings.replace("aaa", "a", "b")`, "bbb"},
+		{`strings.replace("aaa", "a", "b", 2)`, "bba"},
+		{`strings.repeat("ab", 3)`, "ababab"},
+		{`strings.repeat("ab", -1)`, "ERROR: negative count passed to 'repeat': -1"},
+		{`strings.pad_left("7", 3, "0")`, "007"},
+		{`strings.pad_right("é", 3) + "|"`, "é  |"},
+		{`strings.pad_left("long", 2)`, "long"},
+		{`strings.repeat("ab", 9223372036854775807)`, "ERROR: count passed to 'repeat' is too large: 9223372036854775807"},
+		{`strings.repeat("", 9223372036854775807)`, ""},
+		{`strings.pad_left("a", 9223372036854775807)`, "ERROR: width passed to 'pad_left' is too large: 9223372036854775807"},
+		{`strings.pad_left("7", 9223372036854775807, "é")`, "ERROR: width passed to 'pad_left' is too large: 9223372036854775807"},
+		{`strings.pad_right("7", 9223372036854775807)`, "ERROR: width passed to 'pad_right' is too large: 9223372036854775807"},
+		{`strings.chars("hé!")`, "[h, é, !]"},
+		{`strings.bytes("hé")`, "[104, 195, 169]"},
+		{`strings.upper(1)`, "ERROR: argument 1 to 'upper' must be STRING, got INTEGER"},
+		{`strings.split("a")`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`strings.trim("a", "b", "c")`, "ERROR: wrong number of arguments. got=3, want=1 to 2"},
+		{`strings("a")`, "ERROR: namespace strings is not a function"},
+		{`map(["a", "b"], strings.upper)`, "[A, B]"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("[%s] vm error: %s", tt.input, err)
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestMathBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1 + 1.5`, "2.5"},
+		{`7 / 2`, "3"},
+		{`7 / 2.0`, "3.5"},
+		{`-1.5 * 2`, "-3.0"},
+		{`1 < 1.5`, "true"},
+		{`2.5 > 3`, "false"},
+		{`int(3.9) + int(-3.9)`, "0"},
+		{`int("42")`, "42"},
+		{`int("4x")`, "ERROR: could not parse \"4x\" as INTEGER"},
+		{`int(pow(10.0, 30))`, "1000000000000000019884624838656"},
+		{`float(3)`, "3.0"},
+		{`float("2.5")`, "2.5"},
+		{`str(12) + str([1, "a"]) + str("b")`, "12[1, a]b"},
+		{`parse_int("ff", 16)`, "255"},
+		{`parse_int("0x10", 0)`, "16"},
+		{`parse_int("10", 1)`, "ERROR: invalid base passed to 'parse_int': 1"},
+		{`parse_float("1.25")`, "1.25"},
+		{`abs(-5)`, "5"},
+		{`abs(-2.5)`, "2.5"},
+		{`min(3, 1.5, 2)`, "1.5"},
+		{`max([3, 7, 2])`, "7"},
+		{`max()`, "ERROR: 'max' of no values"},
+		{`min(1, "a")`, "ERROR: argument 2 to 'min' must be a number, got STRING"},
+		{`floor(2.7)`, "2"},
+		{`floor(-2.5)`, "-3"},
+		{`ceil(2.1)`, "3"},
+		{`round(2.5)`, "3"},
+		{`round(7)`, "7"},
+		{`sqrt(16)`, "4.0"},
+		{`pow(2, 10)`, "1024"},
+		{`pow(-3, 3)`, "-27"},
+		{`pow(2, 63)`, "9223372036854775808"},
+		{`pow(2, -1)`, "0.5"},
+		{`pow(4, 0.5)`, "2.0"},
+		{`log(E)`, "1.0"},
+		{`log(8, 2)`, "3.0"},
+		{`sin(PI / 2)`, "1.0"},
+		{`cos(0)`, "1.0"},
+		{`atan2(1, 1) * 4`, "3.141592653589793"},
+		{`sqrt("a")`, "ERROR: argument 1 to 'sqrt' must be a number, got STRING"},
+		{`let f = fn(x) { x * PI }; f(2)`, "6.283185307179586"},
+		{`{1: "one"}[1.0]`, "one"},
+		{`len({1.1: "a", 1.9: "b", -1.1: "c"})`, "3"},
+		{`str(1.0 / 3)`, "0.3333333333333333"},
+		{`1.0 / 0`, "Inf"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("[%s] vm error: %s", tt.input, err)
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775808 - 1", "-9223372036854775809"},
+		{"-9223372036854775808", "-9223372036854775808"},
+		{"-(-9223372036854775808)", "9223372036854775808"},
+		{"4611686018427387904 * 4", "18446744073709551616"},
+		{"18446744073709551616 / 4", "4611686018427387904"},
+		{"(9223372036854775807 + 1) - 1", "9223372036854775807"},
+		{"2 ** 100", "1267650600228229401496703205376"},
+		{"2 ** 3 ** 2", "512"},
+		{"-2 ** 2", "-4"},
+		{"2 ** -1", "0.5"},
+		{"2.0 ** 0.5 > 1.4", "true"},
+		{"0x10000000000000000", "18446744073709551616"},
+		{"2 ** 64 > 2 ** 63", "true"},
+		{"2 ** 64 == 18446744073709551616", "true"},
+		{"2 ** 64 != 2 ** 64 + 1", "true"},
+		{"2 ** 64 > 1.5", "true"},
+		{"2 ** 64 + 0.5", "1.8446744073709552e+19"},
+		{"{2 ** 64: \"big\"}[18446744073709551616]", "big"},
+		{"abs(-(2 ** 64))", "18446744073709551616"},
+		{"max(1, 2 ** 70, 3.5)", "1180591620717411303424"},
+		{"int(\"99999999999999999999\")", "99999999999999999999"},
+		{"float(2 ** 64)", "1.8446744073709552e+19"},
+		{"sort([2 ** 64, 1, -(2 ** 64)])", "[-18446744073709551616, 1, 18446744073709551616]"},
+		{"pow(3, 50)", "717897987691852588770249"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("[%s] vm error: %s", tt.input, err)
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestDecimals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0.1d + 0.2d", "0.3"},
+		{"12.50d", "12.50"},
+		{"12.50d * 3", "37.50"},
+		{"1.5d - 2", "-0.5"},
+		{"10d / 4", "2.5"},
+		{"12.50d / 2", "6.25"},
+		{"10.00d / 4", "2.50"},
+		{"1d / 3", "0.3333333333333333333333333333"},
+		{"2d / 3", "0.6666666666666666666666666667"},
+		{"-1d / 8", "-0.125"},
+		{"1.1d ** 2", "1.21"},
+		{"2d ** -2", "0.25"},
+		{"-0.5d", "-0.5"},
+		{"1.50d == 1.5d", "true"},
+		{"1.5d == 1", "false"},
+		{"2d == 2", "true"},
+		{"0.3d > 0.25d", "true"},
+		{"0.3d < 0.5", "true"},
+		{"{1.5d: \"a\"}[1.50d]", "a"},
+		{"1.5 == 1.5d", "false"},
+		{"2.0 == 2d", "true"},
+		{"{1.5: 1}[1.5d]", "null"},
+		{"len(set([1.5, 1.5d]))", "2"},
+		{"decimal(\"12.50\") + decimal(1) + decimal(0.1)", "13.60"},
+		{"decimal(\"1.5e3\")", "1500"},
+		{"decimal(\"1x\")", "ERROR: could not parse \"1x\" as DECIMAL"},
+		{"round(2.345d, 2)", "2.34"},
+		{"round(2.345d, 2, \"half_up\")", "2.35"},
+		{"round(-2.345d, 2, \"floor\")", "-2.35"},
+		{"round(2.5d)", "2"},
+		{"round(2.5d, 0, \"sideways\")", "ERROR: unknown rounding mode \"sideways\""},
+		{"round(2.5, 1)", "ERROR: argument 1 to 'round' must be DECIMAL, got FLOAT"},
+		{"floor(-2.5d) + ceil(2.1d)", "0"},
+		{"int(9.99d)", "9"},
+		{"float(0.5d)", "0.5"},
+		{"str(abs(-3.10d))", "3.10"},
+		{"max(1.5d, 2, 0.5d)", "2"},
+		{"sort([0.3d, 0.1d, 0.2d])", "[0.1, 0.2, 0.3]"},
+		{"1.5d + 1.5", "ERROR: can't mix DECIMAL and FLOAT; convert with decimal() or float()"},
+		{"1d / 0", "ERROR: division by zero"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			if "ERROR: "+err.Error() != tt.expected {
+				t.Errorf("[%s] wrong vm error. want=%s, got=%s", tt.input, tt.expected, err)
+			}
+			continue
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a" + "b" == "ab"`, "true"},
+		{`"a" != "b"`, "true"},
+		{"1 == 1.0", "true"},
+		{"1.5 == 1.5", "true"},
+		{"0.1 + 0.2 == 0.3", "false"},
+		{"let nan = 0.0 / 0; nan == nan", "false"},
+		{"let nan = 0.0 / 0; nan != nan", "true"},
+		{"[1, [2, 3]] == [1, [2, 3]]", "true"},
+		{"[1, [2, 3]] == [1, [2, 4]]", "false"},
+		{"[1, 2] == [1, 2, 3]", "false"},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, "true"},
+		{`{"a": 1} == {"a": 2}`, "false"},
+		{"{1: true} == {1.0: true}", "true"},
+		{`[][0] == {}["a"]`, "true"},
+		{"1 == \"1\"", "false"},
+		{"let f = fn() {}; f == f", "true"},
+		{"fn() {} == fn() {}", "false"},
+		{"0..3 == 0..3", "true"},
+		{`"apple" < "banana"`, "true"},
+		{`"b" > "abc"`, "true"},
+		{"1.5 < 2", "true"},
+		{"[1, 2] < [1, 3]", "true"},
+		{"[1, 2] < [1, 2, 0]", "true"},
+		{"[2] > [1, 5]", "true"},
+		{`1 < "a"`, "ERROR: can't compare INTEGER and STRING"},
+		{"[1] < [\"a\"]", "ERROR: can't compare INTEGER and STRING"},
+		{`"a" > 1`, "ERROR: can't compare STRING and INTEGER"},
+		{"[1.5 < 1.5d, 1.5 > 1.5d, 1.5 == 1.5d]", "[false, false, false]"},
+		{`{"a": 1} > {}`, "ERROR: can't compare HASH and HASH"},
+		{`sort(["b", "c", "a"])`, `[a, b, c]`},
+		{"sort([[2], [1, 2], [1]])", "[[1], [1, 2], [2]]"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			if "ERROR: "+err.Error() != tt.expected {
+				t.Errorf("[%s] wrong vm error. want=%s, got=%s", tt.input, tt.expected, err)
+			}
+			continue
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3}`, "{b: 1, a: 2, 3: 3}"},
+		{`{"b": 1, "a": 2, "b": 3}`, "{b: 3, a: 2}"},
+		{`collect({"z": 1, "y": 2, "x": 3})`, "[z, y, x]"},
+		{`keys({"z": 1, "y": 2})`, "[z, y]"},
+		{`values({"z": 1, "y": 2})`, "[1, 2]"},
+		{`items({"z": 1, "y": 2})`, "[[z, 1], [y, 2]]"},
+		{`keys({})`, "[]"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`has({1: true}, 1.0)`, "true"},
+		{`has({}, [1])`, "ERROR: unusable as hash key: ARRAY"},
+		{`get({"a": 1}, "a", 0)`, "1"},
+		{`get({"a": 1}, "b", 0)`, "0"},
+		{`get({"a": 1}, "b")`, "null"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b")`, "{a: 1, c: 3}"},
+		{`let h = {"a": 1, "b": 2}; delete(h, "a"); h`, "{a: 1, b: 2}"},
+		{`delete({"a": 1}, "z")`, "{a: 1}"},
+		{`merge({"a": 1, "b": 2}, {"c": 3, "a": 4})`, "{a: 4, b: 2, c: 3}"},
+		{`merge({"a": 1})`, "{a: 1}"},
+		{`merge({"a": 1}, [1])`, "ERROR: argument 2 to 'merge' must be HASH, got ARRAY"},
+		{`keys([1])`, "ERROR: argument 1 to 'keys' must be HASH, got ARRAY"},
+		{`get([1], 0)`, "ERROR: argument 1 to 'get' must be HASH, got ARRAY"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			if "ERROR: "+err.Error() != tt.expected {
+				t.Errorf("[%s] wrong vm error. want=%s, got=%s", tt.input, tt.expected, err)
+			}
+			continue
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"#{1, 2, 3}", "#{1, 2, 3}"},
+		{"#{3, 1, 3, 2, 1}", "#{3, 1, 2}"},
+		{"#{}", "#{}"},
+		{"#{1, 1.0, 1d}", "#{1}"},
+		{"len(#{1, 2, 2})", "2"},
+		{"#{[1]}", "ERROR: unusable as set element: ARRAY"},
+		{"set([1, 2, 1])", "#{1, 2}"},
+		{"set(\"abca\")", "#{a, b, c}"},
+		{"set()", "#{}"},
+		{"set(1)", "ERROR: argument to 'set' must be iterable, got INTEGER"},
+		{"let s = #{1}; add(s, 2)", "#{1, 2}"},
+		{"let s = #{1}; add(s, 2); s", "#{1}"},
+		{"add(#{1}, 1)", "#{1}"},
+		{"remove(#{1, 2, 3}, 2)", "#{1, 3}"},
+		{"remove(#{1}, 5)", "#{1}"},
+		{"has(#{1, \"a\"}, \"a\")", "true"},
+		{"has(#{1}, 2)", "false"},
+		{"union(#{1, 2}, #{2, 3}, #{4})", "#{1, 2, 3, 4}"},
+		{"intersection(#{1, 2, 3}, #{3, 2}, #{2})", "#{2}"},
+		{"difference(#{1, 2, 3}, #{2}, #{3})", "#{1}"},
+		{"union(#{1}, [2])", "ERROR: argument 2 to 'union' must be SET, got ARRAY"},
+		{"add([1], 2)", "ERROR: argument 1 to 'add' must be SET, got ARRAY"},
+		{"#{1, 2} == #{2, 1}", "true"},
+		{"#{1, 2} == #{1, 3}", "false"},
+		{"#{1} == {1: true}", "false"},
+		{"collect(#{\"b\", \"a\"})", "[b, a]"},
+		{"map(#{1, 2}, fn(x) { x * 10 })", "[10, 20]"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			if "ERROR: "+err.Error() != tt.expected {
+				t.Errorf("[%s] wrong vm error. want=%s, got=%s", tt.input, tt.expected, err)
+			}
+			continue
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestTuplesAndRecords(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(1, 2)", "(1, 2)"},
+		{"(1,)", "(1,)"},
+		{"()", "()"},
+		{"(1 + 1)", "2"},
+		{"(1, \"a\")[1]", "a"},
+		{"(1, 2, 3)[-1]", "3"},
+		{"(1, 2)[5]", "null"},
+		{"(1, 2, 3)[1:]", "(2, 3)"},
+		{"len((1, 2, 3))", "3"},
+		{"collect((3, 2, 1))", "[3, 2, 1]"},
+		{"(1, (2, 3)) == (1, (2, 3))", "true"},
+		{"(1, 2) == [1, 2]", "false"},
+		{"(1, 2) < (1, 3)", "true"},
+		{"{(1, 2): \"a\"}[(1, 2)]", "a"},
+		{"{(1, 2.0): \"a\"}[(1, 2)]", "a"},
+		{"#{(1, 2), (1, 2), (2, 1)}", "#{(1, 2), (2, 1)}"},
+		{"{(1, [2]): true}", "ERROR: unusable as hash key: TUPLE"},
+		{"record Point { x, y }; Point(1, 2)", "Point{x: 1, y: 2}"},
+		{"record Point { x, y }; Point", "record Point { x, y }"},
+		{"record Point { x, y }; let p = Point(1, 2); p.x + p.y", "3"},
+		{"record Point { x, y }; Point(1, 2)[\"y\"]", "2"},
+		{"record Point { x, y }; Point(1, 2).z", "ERROR: Point has no field z"},
+		{"record Point { x, y }; Point(1)", "ERROR: wrong number of arguments to Point: want=2, got=1"},
+		{"record Point { x, y }; Point(1, 2) == Point(1, 2)", "true"},
+		{"record Point { x, y }; Point(1, 2) == Point(2, 1)", "false"},
+		{"record A { x }; record B { x }; A(1) == B(1)", "false"},
+		{"record Point { x, y }; {Point(1, 2): \"here\"}[Point(1, 2)]", "here"},
+		{"record Point { x, y }; has(#{Point(0, 0)}, Point(0, 0))", "true"},
+		{"record Pair { a, b }; map([1, 2], fn(x) { Pair(x, x * x) })", "[Pair{a: 1, b: 1}, Pair{a: 2, b: 4}]"},
+		{"record Box { v }; let f = fn() { record Box { v }; Box(1) }; f() == Box(1)", "true"},
+		{"record Empty {}; Empty()", "Empty{}"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			if "ERROR: "+err.Error() != tt.expected {
+				t.Errorf("[%s] wrong vm error. want=%s, got=%s", tt.input, tt.expected, err)
+			}
+			continue
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`b"hi"`, `b"hi"`},
+		{`b"\x00\xff\n\"\\"`, `b"\x00\xff\n\"\\"`},
+		{`b"abc"[0]`, "97"},
+		{`b"abc"[-1]`, "99"},
+		{`b"abc"[5]`, "null"},
+		{`b"abcd"[1:3]`, `b"bc"`},
+		{`len(b"\xff\x00")`, "2"},
+		{`b"ab" + b"cd"`, `b"abcd"`},
+		{`b"ab" == b"ab"`, "true"},
+		{`b"ab" == "ab"`, "false"},
+		{`b"ab" < b"b"`, "true"},
+		{`collect(b"hi")`, "[104, 105]"},
+		{`bytes("hé")`, `b"h\xc3\xa9"`},
+		{`bytes([0, 255])`, `b"\x00\xff"`},
+		{`bytes([256])`, "ERROR: elements of the array passed to 'bytes' must be integers from 0 to 255, got 256"},
+		{`str(b"h\xc3\xa9")`, "hé"},
+		{`str(b"\xff")`, "ERROR: BYTES passed to 'str' aren't valid UTF-8"},
+		{`{b"k": 1}[b"k"]`, "1"},
+		{`hex_encode(b"\x01\xab")`, "01ab"},
+		{`hex_decode("01ab")`, `b"\x01\xab"`},
+		{`hex_decode("zz")`, "ERROR: 'hex_decode' failed: encoding/hex: invalid byte: U+007A 'z'"},
+		{`base64_encode("hi")`, "aGk="},
+		{`base64_decode("aGk=")`, `b"hi"`},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			if "ERROR: "+err.Error() != tt.expected {
+				t.Errorf("[%s] wrong vm error. want=%s, got=%s", tt.input, tt.expected, err)
+			}
+			continue
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_stringify({"a": [1, 2.5, (3,)], "b": "s"})`, `{"a":[1,2.5,[3]],"b":"s"}`},
+		{`json_stringify([], 2)`, "[]"},
+		{`json_parse(json_stringify({"z": 1, "a": [true]}))`, "{z: 1, a: [true]}"},
+		{`json_parse(json_stringify({"k": "v"})).k`, "v"},
+		{`json_parse("[1, 2")`, "ERROR: invalid JSON at line 1, column 6: unexpected end of JSON input"},
+		{`json_parse("[1, 1e400]")`, "ERROR: invalid JSON at line 1, column 5: number 1e400 out of range"},
+		{`json_parse(1)`, "ERROR: argument 1 to 'json_parse' must be STRING, got INTEGER"},
+		{`json_stringify({"f": fn(x) { x }})`, `ERROR: can't encode FUNCTION as JSON at $["f"]`},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		err := comp.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("[%s] compiler error: %+v", tt.input, err)
+		}
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			if "ERROR: "+err.Error() != tt.expected {
+				t.Errorf("[%s] wrong vm error. want=%s, got=%s", tt.input, tt.expected, err)
+			}
+			continue
+		}
+		if result := vm.LastPoppedStackElem().Inspect(); result != tt.expected {
+			t.Errorf("[%s] wrong result. want=%s, got=%s", tt.input, tt.expected, result)
+		}
+	}
+}